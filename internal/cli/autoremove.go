@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/installer"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/paths"
+)
+
+func newAutoremoveCmd() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "autoremove",
+		Short: "Uninstall dependency packages that are no longer needed",
+		Long: `Uninstall packages that were installed as dependencies and are no
+longer required by any explicitly installed package.
+
+Examples:
+  gbpm autoremove --dry-run  # List the packages that would be removed
+  gbpm autoremove            # Remove them`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p := paths.NewDefault()
+			statePath := filepath.Join(p.Home, "state.json")
+
+			l, err := lockHome(p)
+			if err != nil {
+				return err
+			}
+			defer l.Release()
+
+			inst, err := installer.New(p, statePath)
+			if err != nil {
+				return fmt.Errorf("failed to create installer: %w", err)
+			}
+
+			orphans := inst.State.Orphans()
+			if len(orphans) == 0 {
+				fmt.Println("No unused dependency packages.")
+				return nil
+			}
+
+			fmt.Println("Unused dependency packages:")
+			for _, name := range orphans {
+				pkg, _ := inst.State.GetPackage(name)
+				fmt.Printf("  %s v%s\n", name, pkg.Version)
+			}
+
+			if dryRun {
+				return nil
+			}
+			fmt.Println()
+
+			// Remove dependents before the packages they depend on. Every
+			// dependent of an orphan is itself an orphan, so this always
+			// makes progress unless the orphans form a cycle.
+			remaining := orphans
+			for len(remaining) > 0 {
+				var next []string
+				for _, name := range remaining {
+					if len(inst.State.Dependents(name)) > 0 {
+						next = append(next, name)
+						continue
+					}
+					if err := inst.Uninstall(name, false); err != nil {
+						return err
+					}
+				}
+
+				if len(next) == len(remaining) {
+					for _, name := range next {
+						if err := inst.Uninstall(name, true); err != nil {
+							return err
+						}
+					}
+					break
+				}
+				remaining = next
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Only list the packages that would be removed")
+
+	return cmd
+}
@@ -4,16 +4,20 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/Foggy-Forge/git-bash-package-manager/internal/paths"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/util"
 )
 
 func newDoctorCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "doctor",
 		Short: "Check environment and configuration",
+		// Report HTTP configuration problems below instead of failing early
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			fmt.Println("Running gbpm diagnostics...")
 
@@ -28,6 +32,30 @@ func newDoctorCmd() *cobra.Command {
 			fmt.Println("GBPM_BIN:", p.Bin)
 			fmt.Println("GBPM_CACHE:", p.Cache)
 			fmt.Println("GBPM_REGISTRY:", p.Registry)
+			fmt.Println("GBPM_APPS:", p.Apps)
+
+			fmt.Println()
+			if proxy := firstEnv("HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"); proxy != "" {
+				fmt.Println("Proxy:", redactProxy(proxy))
+			} else {
+				fmt.Println("Proxy: none")
+			}
+			if noProxy := firstEnv("NO_PROXY", "no_proxy"); noProxy != "" {
+				fmt.Println("No proxy for:", noProxy)
+			}
+			if bundle := os.Getenv("GBPM_CA_BUNDLE"); bundle != "" {
+				fmt.Println("CA bundle:", bundle)
+			}
+			cfg, err := httpConfig()
+			if err == nil {
+				err = util.ConfigureHTTP(cfg)
+			}
+			if err != nil {
+				fmt.Println("WARNING: invalid HTTP configuration:", err)
+			}
+			for _, c := range cfg.Credentials {
+				fmt.Println("Credentials:", c)
+			}
 
 			pathEnv := os.Getenv("PATH")
 			if !isInPath(pathEnv, p.Bin) {
@@ -52,3 +80,22 @@ func isInPath(pathEnv, dir string) bool {
 	}
 	return false
 }
+
+// firstEnv returns the first non-empty environment variable among keys
+func firstEnv(keys ...string) string {
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// redactProxy hides credentials in a proxy setting. Like net/http, a value
+// without a scheme is read as an http:// URL.
+func redactProxy(proxy string) string {
+	if !strings.Contains(proxy, "://") {
+		return strings.TrimPrefix(util.RedactURL("http://"+proxy), "http://")
+	}
+	return util.RedactURL(proxy)
+}
@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/manifest"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/paths"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/state"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/util"
+	pkgversion "github.com/Foggy-Forge/git-bash-package-manager/internal/version"
+)
+
+func newInfoCmd() *cobra.Command {
+	var manifestFile string
+
+	cmd := &cobra.Command{
+		Use:   "info [package]",
+		Short: "Show package information",
+		Long: `Show a package's manifest and its install details.
+
+Examples:
+  gbpm info fzf              # Show a package from the registry
+  gbpm info fzf@0.44.1       # Show a specific version
+  gbpm info --file fzf.yaml  # Show a local manifest`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p := paths.NewDefault()
+			statePath := filepath.Join(p.Home, "state.json")
+
+			s, err := state.Load(statePath)
+			if err != nil {
+				return fmt.Errorf("failed to load state: %w", err)
+			}
+
+			var m *manifest.Manifest
+			var name string
+			switch {
+			case manifestFile != "":
+				m, err = manifest.LoadManifest(manifestFile)
+				if err != nil {
+					return fmt.Errorf("failed to load manifest: %w", err)
+				}
+				name = m.Name
+
+			case len(args) == 1:
+				// State and cache are keyed by the bare package name
+				name = bareName(args[0])
+				reg, err := loadRegistry(p, false)
+				if err != nil {
+					return fmt.Errorf("failed to load registry: %w", err)
+				}
+
+				m, err = loadRegistryManifest(reg, args[0])
+				if err != nil && !s.IsInstalled(name) {
+					return err
+				}
+
+			default:
+				return fmt.Errorf("package name or --file required")
+			}
+
+			pkg, installed := s.GetPackage(name)
+
+			if m != nil {
+				printManifest(m)
+			} else {
+				fmt.Printf("%s (not in registry)\n", name)
+			}
+
+			fmt.Println()
+			if !installed {
+				fmt.Println("Installed:    no")
+			} else {
+				kind := "explicitly"
+				if pkg.AsDependency {
+					kind = "as a dependency"
+				}
+				fmt.Printf("Installed:    v%s (%s, %s)\n", pkg.Version, kind, pkg.InstalledAt.Format("2006-01-02"))
+				for _, v := range pkg.Inactive {
+					fmt.Printf("              v%s (inactive, %s)\n", v.Version, v.InstalledAt.Format("2006-01-02"))
+				}
+
+				if m != nil {
+					switch pkgversion.Compare(m.Version, pkg.Version) {
+					case 1:
+						fmt.Printf("Update:       v%s available\n", m.Version)
+					case 0:
+						fmt.Println("Update:       up to date")
+					}
+				}
+
+				if pin, ok := s.GetPin(name); ok {
+					fmt.Printf("Pinned:       %s\n", pin)
+				}
+
+				if dependents := s.Dependents(name); len(dependents) > 0 {
+					fmt.Printf("Required by:  %s\n", strings.Join(dependents, ", "))
+				}
+
+				if len(pkg.Files) > 0 {
+					fmt.Println("Files:")
+					for _, f := range pkg.Files {
+						fmt.Printf("  %s\n", f)
+					}
+				}
+			}
+
+			size, err := dirSize(filepath.Join(p.Cache, name))
+			if err != nil {
+				return fmt.Errorf("failed to read cache: %w", err)
+			}
+			fmt.Printf("Cache:        %s\n", util.FormatSize(size))
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&manifestFile, "file", "f", "", "Show a local manifest file")
+
+	return cmd
+}
+
+// printManifest prints the user-facing fields of a manifest
+func printManifest(m *manifest.Manifest) {
+	fmt.Printf("%s v%s\n", m.Name, m.Version)
+	if m.Description != "" {
+		fmt.Printf("  %s\n", m.Description)
+	}
+	fmt.Println()
+
+	if m.Homepage != "" {
+		fmt.Printf("Homepage:     %s\n", m.Homepage)
+	}
+	if m.License != "" {
+		fmt.Printf("License:      %s\n", m.License)
+	}
+	if len(m.Tags) > 0 {
+		fmt.Printf("Tags:         %s\n", strings.Join(m.Tags, ", "))
+	}
+	if len(m.Depends) > 0 {
+		deps := make([]string, len(m.Depends))
+		for idx, d := range m.Depends {
+			deps[idx] = d.String()
+		}
+		fmt.Printf("Depends:      %s\n", strings.Join(deps, ", "))
+	}
+
+	fmt.Println("Platforms:")
+	for _, pl := range m.Platforms {
+		marker := ""
+		if pl.OS == runtime.GOOS && pl.Arch == runtime.GOARCH {
+			marker = " (current)"
+		}
+		checksum := "no checksum"
+		if pl.Checksum != "" {
+			checksum = strings.SplitN(pl.Checksum, ":", 2)[0]
+		}
+		fmt.Printf("  %s/%s%s, %s\n", pl.OS, pl.Arch, marker, checksum)
+	}
+
+	fmt.Println("Steps:")
+	for idx, step := range m.Install.Steps {
+		switch {
+		case step.From != "" && step.To != "":
+			fmt.Printf("  %d. %s %s -> %s\n", idx+1, step.Type, step.From, step.To)
+		case step.To != "":
+			fmt.Printf("  %d. %s -> %s\n", idx+1, step.Type, step.To)
+		default:
+			fmt.Printf("  %d. %s\n", idx+1, step.Type)
+		}
+	}
+}
+
+// dirSize returns the total size of the files under dir, or 0 if it does not exist
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
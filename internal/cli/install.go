@@ -2,7 +2,10 @@ package cli
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -10,36 +13,80 @@ import (
 	"github.com/Foggy-Forge/git-bash-package-manager/internal/manifest"
 	"github.com/Foggy-Forge/git-bash-package-manager/internal/paths"
 	"github.com/Foggy-Forge/git-bash-package-manager/internal/registry"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/resolver"
+	pkgversion "github.com/Foggy-Forge/git-bash-package-manager/internal/version"
 )
 
 func newInstallCmd() *cobra.Command {
 	var manifestFile string
+	var requireChecksum bool
+	var keep bool
+	var ignorePins bool
+	var jobs int
 
 	cmd := &cobra.Command{
-		Use:   "install [package]",
-		Short: "Install a package",
-		Long: `Install a package from the registry or from a local manifest file.
+		Use:   "install [package...]",
+		Short: "Install packages",
+		Long: `Install packages from the registry or from a local manifest file.
+
+When several packages are installed, their downloads (including those of
+dependencies) run concurrently before the packages are installed one at a
+time.
 
 Examples:
   gbpm install fzf              # Install from registry
-  gbpm install --file fzf.yaml  # Install from local manifest`,
+  gbpm install fzf bat ripgrep  # Install several packages
+  gbpm install fzf@0.44.1       # Install a specific version
+  gbpm install fzf@~0.44        # Install the newest 0.44.x
+  gbpm install -j 8 fzf bat     # Download up to 8 files at a time
+  gbpm install work/deploy-cli  # Install from a specific registry
+  gbpm install --file fzf.yaml  # Install from local manifest
+  gbpm install --require-checksum fzf  # Refuse manifests without a checksum
+  gbpm install --keep -f tf-1.5.yaml   # Keep the installed version alongside
+
+Pinned packages (see 'gbpm pin') are installed at a version within their
+pin unless --ignore-pins is given.
+
+Checksums can also be required for every install by setting
+GBPM_REQUIRE_CHECKSUM=1. The default number of concurrent downloads can be
+set with GBPM_JOBS.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			p := paths.NewDefault()
 			statePath := filepath.Join(p.Home, "state.json")
 
+			l, err := lockHome(p)
+			if err != nil {
+				return err
+			}
+			defer l.Release()
+
 			inst, err := installer.New(p, statePath)
 			if err != nil {
 				return fmt.Errorf("failed to create installer: %w", err)
 			}
+			if requireChecksum {
+				inst.RequireChecksum = true
+			}
+			inst.KeepPrevious = keep
+
+			// Load registry, also used to resolve dependencies of --file manifests
+			reg, err := loadRegistry(p, true)
+			if err != nil {
+				return fmt.Errorf("failed to load registry: %w", err)
+			}
 
 			// Install from file
 			if manifestFile != "" {
+				if len(args) > 0 {
+					return fmt.Errorf("cannot combine package names with --file")
+				}
+
 				m, err := manifest.LoadManifest(manifestFile)
 				if err != nil {
 					return fmt.Errorf("failed to load manifest: %w", err)
 				}
 
-				return inst.Install(m)
+				return installWithDependencies(inst, reg, []*manifest.Manifest{m}, installOptions{jobs: jobs, ignorePins: ignorePins})
 			}
 
 			// Install from registry
@@ -47,30 +94,193 @@ Examples:
 				return fmt.Errorf("package name or --file required")
 			}
 
-			packageName := args[0]
+			var roots []*manifest.Manifest
+			for _, packageName := range args {
+				// A pin selects the version unless one is given
+				if pin, ok := inst.State.GetPin(bareName(packageName)); ok && !ignorePins &&
+					!strings.Contains(packageName, "@") {
+					packageName += "@" + pin
+				}
 
-			// Load registry
-			reg, err := registry.New(p.Registry)
-			if err != nil {
-				return fmt.Errorf("failed to load registry: %w", err)
-			}
+				m, err := loadRegistryManifest(reg, packageName)
+				if err != nil {
+					return err
+				}
 
-			// Find manifest
-			manifestPath, err := reg.FindManifest(packageName)
-			if err != nil {
-				return fmt.Errorf("package not found: %w\n\nRun 'gbpm update' to update the package registry", err)
+				// Skip packages that are already installed when installing
+				// several, unless they were only installed as dependencies and
+				// need marking as explicitly installed
+				if pkg, ok := inst.State.GetPackage(m.Name); ok && len(args) > 1 && !pkg.AsDependency &&
+					pkgversion.Compare(pkg.Version, m.Version) == 0 {
+					fmt.Printf("✓ %s v%s is already installed, skipping\n", m.Name, pkg.Version)
+					continue
+				}
+				roots = append(roots, m)
 			}
-
-			m, err := manifest.LoadManifest(manifestPath)
-			if err != nil {
-				return fmt.Errorf("failed to load manifest: %w", err)
+			if len(roots) == 0 {
+				return nil
 			}
 
-			return inst.Install(m)
+			return installWithDependencies(inst, reg, roots, installOptions{jobs: jobs, ignorePins: ignorePins})
 		},
 	}
 
 	cmd.Flags().StringVarP(&manifestFile, "file", "f", "", "Install from a local manifest file")
+	cmd.Flags().BoolVar(&requireChecksum, "require-checksum", false, "Refuse to install packages without a checksum")
+	cmd.Flags().BoolVar(&keep, "keep", false, "Keep the installed version side by side instead of replacing it (see 'gbpm switch')")
+	cmd.Flags().BoolVar(&ignorePins, "ignore-pins", false, "Install versions outside the pins set with 'gbpm pin'")
+	cmd.Flags().IntVarP(&jobs, "jobs", "j", defaultJobs(), "Maximum number of concurrent downloads")
 
 	return cmd
 }
+
+// defaultJobs returns the download concurrency from GBPM_JOBS, or the
+// installer default
+func defaultJobs() int {
+	if n, err := strconv.Atoi(os.Getenv("GBPM_JOBS")); err == nil && n > 0 {
+		return n
+	}
+	return installer.DefaultJobs
+}
+
+// installOptions controls how installWithDependencies installs packages
+type installOptions struct {
+	// jobs is the maximum number of concurrent downloads
+	jobs int
+
+	// ignorePins allows versions outside the pins set with 'gbpm pin'
+	ignorePins bool
+
+	// upgrade keeps roots that were installed as dependencies marked as
+	// such, instead of making them explicit
+	upgrade bool
+}
+
+// installWithDependencies installs roots after any of their dependencies
+// that are missing or do not satisfy their version constraints. Downloads
+// run up to opts.jobs at a time; packages are then installed one by one so
+// every state change is saved in order. Unless opts.ignorePins is set,
+// pinned packages are resolved within their pin and any version outside it
+// is refused.
+func installWithDependencies(inst *installer.Installer, reg *registry.Set, roots []*manifest.Manifest, opts installOptions) error {
+	lookup := func(name string, constraint *pkgversion.Constraint) (*manifest.Manifest, error) {
+		if pin, ok := inst.State.GetPin(name); ok && !opts.ignorePins && constraint == nil {
+			return loadRegistryManifest(reg, name+"@"+pin)
+		}
+		if constraint == nil {
+			return loadRegistryManifest(reg, name)
+		}
+		return loadRegistryVersion(reg, name, constraint)
+	}
+	installed := func(name string) (string, bool) {
+		pkg, ok := inst.State.GetPackage(name)
+		if !ok {
+			return "", false
+		}
+		return pkg.Version, true
+	}
+
+	plan, err := resolver.Resolve(roots, lookup, installed)
+	if err != nil {
+		return fmt.Errorf("failed to resolve dependencies: %w", err)
+	}
+
+	if !opts.ignorePins {
+		for _, step := range plan {
+			if err := checkPin(inst.State, step.Manifest); err != nil {
+				return err
+			}
+		}
+	}
+
+	deps := 0
+	for _, step := range plan {
+		if step.Dependency {
+			deps++
+		}
+	}
+	if deps > 0 {
+		if len(roots) == 1 {
+			fmt.Printf("Installing %d dependencies of %s first\n", deps, roots[0].Name)
+		} else {
+			fmt.Printf("Installing %d packages and %d dependencies\n", len(roots), deps)
+		}
+	}
+
+	manifests := make([]*manifest.Manifest, len(plan))
+	for idx, step := range plan {
+		manifests[idx] = step.Manifest
+	}
+	if err := inst.Prefetch(manifests, opts.jobs); err != nil {
+		return err
+	}
+
+	for _, step := range plan {
+		if step.Dependency {
+			if err := inst.InstallDependency(step.Manifest); err != nil {
+				return fmt.Errorf("failed to install dependency %s: %w", step.Manifest.Name, err)
+			}
+			continue
+		}
+		install := inst.Install
+		if opts.upgrade {
+			install = inst.Upgrade
+		}
+		if err := install(step.Manifest); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadRegistryManifest finds and loads the manifest for a package in the
+// registries. The name may be qualified with a registry, e.g. "bucket/pkg",
+// and followed by a version or constraint, e.g. "pkg@1.2.3" or "pkg@~1.2".
+func loadRegistryManifest(reg *registry.Set, name string) (*manifest.Manifest, error) {
+	if name, spec, ok := strings.Cut(name, "@"); ok {
+		if spec == "" {
+			return nil, fmt.Errorf("missing version after '@' in %s@", name)
+		}
+		constraint, err := pkgversion.ParseConstraint(spec)
+		if err != nil {
+			return nil, err
+		}
+		return loadRegistryVersion(reg, name, constraint)
+	}
+
+	r, manifestPath, err := reg.FindManifest(name)
+	if err != nil {
+		return nil, fmt.Errorf("package not found: %w\n\nRun 'gbpm update' to update the package registry", err)
+	}
+
+	m, err := manifest.LoadManifest(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load manifest: %w", err)
+	}
+	m.Registry = r.Name
+
+	return m, nil
+}
+
+// loadRegistryVersion finds and loads the manifest of the highest version of
+// a package in the registries that satisfies constraint
+func loadRegistryVersion(reg *registry.Set, name string, constraint *pkgversion.Constraint) (*manifest.Manifest, error) {
+	r, m, err := reg.FindManifestVersion(name, constraint)
+	if err != nil {
+		return nil, fmt.Errorf("package not found: %w\n\nRun 'gbpm update' to update the package registry", err)
+	}
+	m.Registry = r.Name
+
+	return m, nil
+}
+
+// bareName returns a package name without its registry, e.g. "pkg" for
+// "bucket/pkg@1.0"
+func bareName(name string) string {
+	name, _, _ = strings.Cut(name, "@")
+	if _, pkg, ok := strings.Cut(name, "/"); ok {
+		return pkg
+	}
+	return name
+}
@@ -35,6 +35,12 @@ func newListCmd() *cobra.Command {
 					name, 
 					pkg.Version, 
 					pkg.InstalledAt.Format("2006-01-02"))
+				if pin, ok := s.GetPin(name); ok {
+					fmt.Printf("    pinned to %s\n", pin)
+				}
+				for _, v := range pkg.Inactive {
+					fmt.Printf("    also v%s (inactive)\n", v.Version)
+				}
 			}
 
 			return nil
@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/manifest"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/paths"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/registry"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/state"
+	pkgversion "github.com/Foggy-Forge/git-bash-package-manager/internal/version"
+)
+
+// outdatedPackage is an installed package with a newer manifest in the registry
+type outdatedPackage struct {
+	Name     string
+	Current  string
+	Latest   string
+	Manifest *manifest.Manifest
+
+	// HeldBy is the pin that keeps the package from being upgraded to Latest
+	HeldBy string
+
+	// RolledBack is set when Latest is still installed but inactive, after
+	// 'gbpm rollback' or 'gbpm switch'. Upgrades leave it to 'gbpm switch'.
+	RolledBack bool
+}
+
+// held reports whether the package is left as it is by upgrades
+func (o outdatedPackage) held() bool {
+	return o.HeldBy != "" || o.RolledBack
+}
+
+// available describes the version a package can be upgraded to
+func (o outdatedPackage) available() string {
+	if o.HeldBy != "" {
+		return fmt.Sprintf("v%s (held, pinned to %s)", o.Latest, o.HeldBy)
+	}
+	if o.RolledBack {
+		return fmt.Sprintf("v%s (rolled back, run 'gbpm switch %s %s')", o.Latest, o.Name, o.Latest)
+	}
+	return "v" + o.Latest
+}
+
+func newOutdatedCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "outdated",
+		Short: "List installed packages with newer versions in the registry",
+		Long: `Compare every installed package with its manifest in the registry
+and list those that have a newer version available. Packages pinned with
+'gbpm pin' are listed as held unless a newer version within the pin exists.
+Packages rolled back from a version that is still installed are listed
+as rolled back; use 'gbpm switch' to return to it.
+
+Run 'gbpm update' first to refresh the registry.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p := paths.NewDefault()
+			statePath := filepath.Join(p.Home, "state.json")
+
+			s, err := state.Load(statePath)
+			if err != nil {
+				return fmt.Errorf("failed to load state: %w", err)
+			}
+
+			reg, err := loadRegistry(p, false)
+			if err != nil {
+				return fmt.Errorf("failed to load registry: %w", err)
+			}
+
+			outdated, err := findOutdated(s, reg, nil, false)
+			if err != nil {
+				return err
+			}
+
+			if len(outdated) == 0 {
+				fmt.Println("All packages are up to date.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tINSTALLED\tAVAILABLE")
+			for _, o := range outdated {
+				fmt.Fprintf(w, "%s\tv%s\t%s\n", o.Name, o.Current, o.available())
+			}
+			return w.Flush()
+		},
+	}
+}
+
+// findOutdated compares installed packages with the registry. If names is
+// empty every installed package is checked, otherwise only the named ones.
+// Packages missing from the registry (e.g. installed with --file) are skipped.
+// Pinned packages are upgraded to the newest version within their pin, and
+// held if there is none, unless ignorePins is set. Packages whose newer
+// version is already installed but inactive are marked as rolled back.
+func findOutdated(s *state.State, reg *registry.Set, names []string, ignorePins bool) ([]outdatedPackage, error) {
+	if len(names) == 0 {
+		for name := range s.Installed {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var outdated []outdatedPackage
+	for _, name := range names {
+		pkg, ok := s.GetPackage(name)
+		if !ok {
+			return nil, fmt.Errorf("package %s is not installed", name)
+		}
+
+		// Prefer the registry the package was installed from
+		lookupName := name
+		if _, ok := reg.Get(pkg.Registry); ok {
+			lookupName = pkg.Registry + "/" + name
+		}
+
+		m, err := loadRegistryManifest(reg, lookupName)
+		if err != nil {
+			fmt.Printf("Warning: skipping %s: %v\n", name, err)
+			continue
+		}
+
+		if pkgversion.Compare(m.Version, pkg.Version) <= 0 {
+			continue
+		}
+
+		if pin, ok := s.GetPin(name); ok && !ignorePins && !pinAllows(pin, m.Version) {
+			held := outdatedPackage{
+				Name:    name,
+				Current: pkg.Version,
+				Latest:  m.Version,
+				HeldBy:  pin,
+			}
+
+			// A bare version pin allows nothing newer
+			if !pkgversion.IsConstraint(pin) {
+				outdated = append(outdated, held)
+				continue
+			}
+
+			allowed, err := loadRegistryManifest(reg, lookupName+"@"+pin)
+			if err != nil || pkgversion.Compare(allowed.Version, pkg.Version) <= 0 {
+				outdated = append(outdated, held)
+				continue
+			}
+			m = allowed
+		}
+
+		_, rolledBack := pkg.InactiveVersion(m.Version)
+		outdated = append(outdated, outdatedPackage{
+			Name:       name,
+			Current:    pkg.Version,
+			Latest:     m.Version,
+			Manifest:   m,
+			RolledBack: rolledBack,
+		})
+	}
+
+	return outdated, nil
+}
@@ -18,6 +18,7 @@ func newPathsCmd() *cobra.Command {
 			fmt.Println("GBPM_BIN:", p.Bin)
 			fmt.Println("GBPM_CACHE:", p.Cache)
 			fmt.Println("GBPM_REGISTRY:", p.Registry)
+			fmt.Println("GBPM_APPS:", p.Apps)
 			return nil
 		},
 	}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/manifest"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/paths"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/state"
+	pkgversion "github.com/Foggy-Forge/git-bash-package-manager/internal/version"
+)
+
+func newPinCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "pin <package> [version]",
+		Short: "Hold a package at a version",
+		Long: `Pin a package so that upgrades leave it alone.
+
+Without a version the package is pinned at its installed version. The
+version may also be a constraint, allowing upgrades within it. Pinned
+packages are shown as held by 'gbpm outdated' and 'gbpm upgrade', and
+installing a version outside the pin requires --ignore-pins.
+
+Examples:
+  gbpm pin terraform          # Hold at the installed version
+  gbpm pin terraform 1.5.7    # Hold at 1.5.7
+  gbpm pin fzf "~0.44"        # Allow 0.44.x only`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p := paths.NewDefault()
+			statePath := filepath.Join(p.Home, "state.json")
+
+			l, err := lockHome(p)
+			if err != nil {
+				return err
+			}
+			defer l.Release()
+
+			s, err := state.Load(statePath)
+			if err != nil {
+				return fmt.Errorf("failed to load state: %w", err)
+			}
+
+			name := args[0]
+			pkg, installed := s.GetPackage(name)
+
+			var pin string
+			if len(args) == 2 {
+				pin = args[1]
+				if _, err := pkgversion.ParseConstraint(pin); err != nil {
+					return err
+				}
+			} else {
+				if !installed {
+					return fmt.Errorf("package %s is not installed, give a version to pin it to", name)
+				}
+				pin = pkg.Version
+			}
+
+			s.Pin(name, pin)
+			if err := s.Save(statePath); err != nil {
+				return fmt.Errorf("failed to save state: %w", err)
+			}
+
+			fmt.Printf("✓ Pinned %s to %s\n", name, pin)
+			if installed && !pinAllows(pin, pkg.Version) {
+				fmt.Printf("Warning: installed v%s is outside the pin, run 'gbpm install %s' to install a pinned version\n",
+					pkg.Version, name)
+			}
+			return nil
+		},
+	}
+}
+
+func newUnpinCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "unpin <package>",
+		Short: "Let a pinned package be upgraded again",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p := paths.NewDefault()
+			statePath := filepath.Join(p.Home, "state.json")
+
+			l, err := lockHome(p)
+			if err != nil {
+				return err
+			}
+			defer l.Release()
+
+			s, err := state.Load(statePath)
+			if err != nil {
+				return fmt.Errorf("failed to load state: %w", err)
+			}
+
+			name := args[0]
+			if !s.Unpin(name) {
+				return fmt.Errorf("package %s is not pinned", name)
+			}
+			if err := s.Save(statePath); err != nil {
+				return fmt.Errorf("failed to save state: %w", err)
+			}
+
+			fmt.Printf("✓ Unpinned %s\n", name)
+			return nil
+		},
+	}
+}
+
+// pinAllows reports whether a package pinned to pin may be at version v
+func pinAllows(pin, v string) bool {
+	c, err := pkgversion.ParseConstraint(pin)
+	if err != nil {
+		return false
+	}
+	return c.CheckString(v)
+}
+
+// checkPin refuses a manifest whose version is outside the pin of its package
+func checkPin(s *state.State, m *manifest.Manifest) error {
+	pin, ok := s.GetPin(m.Name)
+	if !ok || pinAllows(pin, m.Version) {
+		return nil
+	}
+	return fmt.Errorf("%s is pinned to %s, refusing to install v%s (use --ignore-pins or 'gbpm unpin %s')",
+		m.Name, pin, m.Version, m.Name)
+}
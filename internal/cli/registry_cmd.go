@@ -0,0 +1,203 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/paths"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/registry"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/util"
+)
+
+func newRegistryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "registry",
+		Short:   "Manage package registries",
+		Aliases: []string{"bucket"},
+		Long: `Manage the package registries ("buckets") gbpm installs from.
+
+Unqualified package names resolve to the registry with the highest priority
+that has the package; use <registry>/<package> to pick one explicitly.`,
+	}
+
+	cmd.AddCommand(
+		newRegistryAddCmd(),
+		newRegistryRemoveCmd(),
+		newRegistryListCmd(),
+		newRegistryPriorityCmd(),
+	)
+
+	return cmd
+}
+
+func newRegistryAddCmd() *cobra.Command {
+	var priority int
+
+	cmd := &cobra.Command{
+		Use:   "add <name> <url>",
+		Short: "Add a registry and fetch it",
+		Long: `Add a registry and fetch it.
+
+The source type is chosen from the URL:
+  *.tar.gz, *.tgz         tarball snapshot, downloaded and unpacked
+  file://..., local path  directory, used in place
+  anything else           git repository, cloned
+
+Examples:
+  gbpm registry add work https://git.example.com/tools/gbpm-registry.git
+  gbpm registry add work https://git.example.com/tools/gbpm-registry.git --priority 10
+  gbpm registry add offline /mnt/usb/gbpm-registry
+  gbpm registry add snapshot https://mirror.example.com/gbpm-registry.tar.gz`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p := paths.NewDefault()
+
+			l, err := lockHome(p)
+			if err != nil {
+				return err
+			}
+			defer l.Release()
+
+			reg, err := loadRegistry(p, true)
+			if err != nil {
+				return fmt.Errorf("failed to load registries: %w", err)
+			}
+
+			r, err := reg.Add(args[0], args[1], priority)
+			if err != nil {
+				return err
+			}
+
+			if err := r.Pull(); err != nil {
+				if r.Type() != registry.TypeDir {
+					os.RemoveAll(r.Path)
+				}
+				return err
+			}
+
+			if _, err := r.BuildIndex(); err != nil {
+				fmt.Printf("Warning: failed to build index for registry %s: %v\n", r.Name, err)
+			}
+
+			if err := reg.Save(); err != nil {
+				return err
+			}
+
+			fmt.Printf("✓ Added registry %s\n", r.Name)
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVarP(&priority, "priority", "p", 0, "Resolution priority, higher is searched first")
+
+	return cmd
+}
+
+func newRegistryRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "remove <name>",
+		Short:   "Remove a registry and delete its clone",
+		Aliases: []string{"rm"},
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p := paths.NewDefault()
+
+			l, err := lockHome(p)
+			if err != nil {
+				return err
+			}
+			defer l.Release()
+
+			reg, err := loadRegistry(p, true)
+			if err != nil {
+				return fmt.Errorf("failed to load registries: %w", err)
+			}
+
+			if err := reg.Remove(args[0]); err != nil {
+				return err
+			}
+
+			if err := reg.Save(); err != nil {
+				return err
+			}
+
+			fmt.Printf("✓ Removed registry %s\n", args[0])
+			return nil
+		},
+	}
+}
+
+func newRegistryListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Short:   "List registries in resolution order",
+		Aliases: []string{"ls"},
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p := paths.NewDefault()
+
+			reg, err := loadRegistry(p, false)
+			if err != nil {
+				return fmt.Errorf("failed to load registries: %w", err)
+			}
+
+			if len(reg.Registries) == 0 {
+				fmt.Println("No registries configured.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tPRIORITY\tTYPE\tURL\tSTATUS")
+			for _, r := range reg.Registries {
+				status := "available"
+				if _, err := os.Stat(r.Path); err != nil {
+					status = "missing, run 'gbpm update'"
+				}
+				fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", r.Name, r.Priority, r.Type(), util.RedactURL(r.URL), status)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newRegistryPriorityCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "priority <name> <priority>",
+		Short: "Change a registry's resolution priority",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			priority, err := strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid priority %q: %w", args[1], err)
+			}
+
+			p := paths.NewDefault()
+
+			l, err := lockHome(p)
+			if err != nil {
+				return err
+			}
+			defer l.Release()
+
+			reg, err := loadRegistry(p, true)
+			if err != nil {
+				return fmt.Errorf("failed to load registries: %w", err)
+			}
+
+			if err := reg.SetPriority(args[0], priority); err != nil {
+				return err
+			}
+
+			if err := reg.Save(); err != nil {
+				return err
+			}
+
+			fmt.Printf("✓ Registry %s now has priority %d\n", args[0], priority)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/installer"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/paths"
+)
+
+func newRollbackCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rollback <package>",
+		Short: "Restore the previously installed version of a package",
+		Long: `Restore the version of a package that was active before the last
+upgrade, switch or rollback.
+
+The previous version's files and manifest are retained when a package is
+upgraded, so rolling back does not need the network. GBPM_KEEP_VERSIONS
+sets how many previous versions are retained (default 1, 0 disables).
+
+Examples:
+  gbpm upgrade jq
+  gbpm rollback jq`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p := paths.NewDefault()
+			statePath := filepath.Join(p.Home, "state.json")
+
+			l, err := lockHome(p)
+			if err != nil {
+				return err
+			}
+			defer l.Release()
+
+			inst, err := installer.New(p, statePath)
+			if err != nil {
+				return fmt.Errorf("failed to create installer: %w", err)
+			}
+
+			return inst.Rollback(args[0])
+		},
+	}
+}
@@ -1,7 +1,18 @@
 package cli
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
 	"github.com/spf13/cobra"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/lock"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/paths"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/registry"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/util"
 )
 
 var (
@@ -17,6 +28,13 @@ func newRootCmd() *cobra.Command {
 		Use:   "gbpm",
 		Short: "gbpm is a lightweight package manager for Git Bash",
 		Long:  "gbpm installs and manages CLI tools and scripts for Git Bash on Windows.",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := httpConfig()
+			if err != nil {
+				return err
+			}
+			return util.ConfigureHTTP(cfg)
+		},
 	}
 
 	cmd.AddCommand(
@@ -25,10 +43,95 @@ func newRootCmd() *cobra.Command {
 		newPathsCmd(),
 		newInstallCmd(),
 		newUninstallCmd(),
+		newAutoremoveCmd(),
 		newListCmd(),
+		newSearchCmd(),
+		newInfoCmd(),
 		newUpdateCmd(),
+		newRegistryCmd(),
+		newOutdatedCmd(),
 		newUpgradeCmd(),
+		newSwitchCmd(),
+		newRollbackCmd(),
+		newPinCmd(),
+		newUnpinCmd(),
+		newSelfUpgradeCmd(),
 	)
 
 	return cmd
 }
+
+// httpConfig builds the shared HTTP client configuration from the
+// environment and the credentials file (GBPM_CREDENTIALS, default
+// $GBPM_HOME/credentials.json). Entries in the file take precedence over
+// the GitHub token from the environment.
+func httpConfig() (util.HTTPConfig, error) {
+	cfg, err := util.HTTPConfigFromEnv("gbpm/" + version)
+	if err != nil {
+		return cfg, err
+	}
+
+	creds, err := util.LoadCredentials(credentialsPath())
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Credentials = append(creds, util.GitHubCredentialsFromEnv()...)
+
+	return cfg, nil
+}
+
+// credentialsPath returns the path of the per-host credentials file
+func credentialsPath() string {
+	if path := os.Getenv("GBPM_CREDENTIALS"); path != "" {
+		return path
+	}
+	return filepath.Join(paths.NewDefault().Home, "credentials.json")
+}
+
+// defaultLockTimeout is how long a command waits for another gbpm to finish
+const defaultLockTimeout = 60 * time.Second
+
+// lockHome takes the exclusive lock on GBPM_HOME that every command changing
+// it holds, waiting up to GBPM_LOCK_TIMEOUT (default 60s) for another gbpm
+// process to finish
+func lockHome(p *paths.Paths) (*lock.Lock, error) {
+	timeout := defaultLockTimeout
+	if v := os.Getenv("GBPM_LOCK_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GBPM_LOCK_TIMEOUT %q: %w", v, err)
+		}
+		timeout = d
+	}
+
+	path := filepath.Join(p.Home, "gbpm.lock")
+	l, err := lock.Acquire(path, timeout, func() {
+		fmt.Printf("Waiting for another gbpm to finish (lock %s)...\n", path)
+	})
+	if errors.Is(err, lock.ErrBusy) {
+		return nil, fmt.Errorf("%w (lock %s is held), try again once it has finished", err, path)
+	}
+	return l, err
+}
+
+// loadRegistry loads the registry configuration. A registry in the layout
+// of older versions of gbpm is migrated first, under the home lock, which is
+// taken here unless the caller already holds it.
+func loadRegistry(p *paths.Paths, locked bool) (*registry.Set, error) {
+	if registry.NeedsMigration(p.Registry) {
+		if !locked {
+			l, err := lockHome(p)
+			if err != nil {
+				return nil, err
+			}
+			defer l.Release()
+		}
+
+		// Another gbpm may have migrated it while we waited for the lock
+		if err := registry.MigrateLegacyLayout(p.Registry); err != nil {
+			return nil, err
+		}
+	}
+
+	return registry.Load(p.Registry)
+}
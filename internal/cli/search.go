@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/paths"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/registry"
+)
+
+func newSearchCmd() *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "search [term]",
+		Short: "Search the package registry",
+		Long: `Search package names, descriptions, homepages and tags in the registry.
+
+Searches use the registry index that 'gbpm update' rebuilds after pulling.
+Without a term every package is listed.
+
+Examples:
+  gbpm search fuzzy
+  gbpm search --json grep`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			term := ""
+			if len(args) > 0 {
+				term = args[0]
+			}
+
+			p := paths.NewDefault()
+
+			reg, err := loadRegistry(p, false)
+			if err != nil {
+				return fmt.Errorf("failed to load registry: %w", err)
+			}
+
+			results, err := reg.Search(term)
+			if err != nil {
+				return err
+			}
+
+			if jsonOutput {
+				if results == nil {
+					results = []registry.IndexEntry{}
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(results)
+			}
+
+			if len(results) == 0 {
+				fmt.Printf("No packages found matching '%s'.\n", term)
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tVERSION\tREGISTRY\tDESCRIPTION")
+			for _, e := range results {
+				fmt.Fprintf(w, "%s\tv%s\t%s\t%s\n", e.Name, e.Version, e.Registry, e.Description)
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print results as JSON")
+
+	return cmd
+}
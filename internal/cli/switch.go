@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/installer"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/paths"
+)
+
+func newSwitchCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "switch <package> <version>",
+		Short: "Switch the active version of a package",
+		Long: `Switch the active version of a package between installed versions.
+
+Versions are kept side by side when installed with --keep, and previous
+versions are retained for rollback when upgrading. Switching repoints the
+package's bin entries to the chosen version.
+
+Examples:
+  gbpm install --keep --file terraform-1.5.7.yaml
+  gbpm switch terraform 1.6.0`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p := paths.NewDefault()
+			statePath := filepath.Join(p.Home, "state.json")
+
+			l, err := lockHome(p)
+			if err != nil {
+				return err
+			}
+			defer l.Release()
+
+			inst, err := installer.New(p, statePath)
+			if err != nil {
+				return fmt.Errorf("failed to create installer: %w", err)
+			}
+
+			return inst.Switch(args[0], args[1])
+		},
+	}
+}
@@ -11,7 +11,9 @@ import (
 )
 
 func newUninstallCmd() *cobra.Command {
-	return &cobra.Command{
+	var force bool
+
+	cmd := &cobra.Command{
 		Use:   "uninstall <package>",
 		Short: "Uninstall a package",
 		Args:  cobra.ExactArgs(1),
@@ -21,12 +23,22 @@ func newUninstallCmd() *cobra.Command {
 			p := paths.NewDefault()
 			statePath := filepath.Join(p.Home, "state.json")
 
+			l, err := lockHome(p)
+			if err != nil {
+				return err
+			}
+			defer l.Release()
+
 			inst, err := installer.New(p, statePath)
 			if err != nil {
 				return fmt.Errorf("failed to create installer: %w", err)
 			}
 
-			return inst.Uninstall(packageName)
+			return inst.Uninstall(packageName, force)
 		},
 	}
+
+	cmd.Flags().BoolVar(&force, "force", false, "Uninstall even if other packages depend on it")
+
+	return cmd
 }
@@ -6,20 +6,25 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/Foggy-Forge/git-bash-package-manager/internal/paths"
-	"github.com/Foggy-Forge/git-bash-package-manager/internal/registry"
 )
 
 func newUpdateCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "update",
-		Short: "Update the package registry",
-		Long:  "Clone or update the package registry from GitHub.",
+		Short: "Update the package registries",
+		Long:  "Clone or update every configured package registry and rebuild their search indexes.",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			p := paths.NewDefault()
 
-			reg, err := registry.New(p.Registry)
+			l, err := lockHome(p)
 			if err != nil {
-				return fmt.Errorf("failed to create registry: %w", err)
+				return err
+			}
+			defer l.Release()
+
+			reg, err := loadRegistry(p, true)
+			if err != nil {
+				return fmt.Errorf("failed to load registries: %w", err)
 			}
 
 			return reg.Pull()
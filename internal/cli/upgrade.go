@@ -2,153 +2,126 @@ package cli
 
 import (
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"path/filepath"
-	"runtime"
-	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
-)
 
-const (
-	repoOwner = "Foggy-Forge"
-	repoName  = "git-bash-package-manager"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/installer"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/manifest"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/paths"
 )
 
 func newUpgradeCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "upgrade",
-		Short: "Upgrade gbpm to the latest version",
-		Long:  "Download and install the latest version of gbpm.",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			fmt.Println("Checking for updates...")
+	var all bool
+	var ignorePins bool
+	var jobs int
 
-			// Get current executable path
-			exePath, err := os.Executable()
-			if err != nil {
-				return fmt.Errorf("failed to get executable path: %w", err)
+	cmd := &cobra.Command{
+		Use:   "upgrade [package...]",
+		Short: "Upgrade installed packages",
+		Long: `Upgrade installed packages to the latest version in the registry.
+
+Examples:
+  gbpm upgrade fzf bat  # Upgrade specific packages
+  gbpm upgrade --all    # Upgrade every outdated package
+
+Packages pinned with 'gbpm pin' are held at their pin unless --ignore-pins
+is given. Packages rolled back from a version that is still installed are
+left alone; use 'gbpm switch' to return to it.
+
+To upgrade gbpm itself, use 'gbpm self-upgrade'.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all && len(args) > 0 {
+				return fmt.Errorf("cannot combine package names with --all")
 			}
-			exePath, err = filepath.EvalSymlinks(exePath)
-			if err != nil {
-				return fmt.Errorf("failed to resolve symlinks: %w", err)
+			if !all && len(args) == 0 {
+				return fmt.Errorf("package name or --all required")
 			}
 
-			// Get latest release info
-			latestVersion, err := getLatestVersion()
+			p := paths.NewDefault()
+			statePath := filepath.Join(p.Home, "state.json")
+
+			l, err := lockHome(p)
 			if err != nil {
-				return fmt.Errorf("failed to check for updates: %w", err)
+				return err
 			}
+			defer l.Release()
 
-			if latestVersion == version {
-				fmt.Printf("Already on the latest version: v%s\n", version)
-				return nil
+			inst, err := installer.New(p, statePath)
+			if err != nil {
+				return fmt.Errorf("failed to create installer: %w", err)
 			}
 
-			fmt.Printf("Current version: v%s\n", version)
-			fmt.Printf("Latest version: %s\n", latestVersion)
-			fmt.Println()
-
-			// Construct download URL
-			binaryName := getBinaryName()
-			downloadURL := fmt.Sprintf(
-				"https://github.com/%s/%s/releases/download/%s/%s",
-				repoOwner, repoName, latestVersion, binaryName,
-			)
-
-			// Download to temp file
-			fmt.Println("Downloading update...")
-			tmpFile, err := os.CreateTemp("", "gbpm-upgrade-*")
+			reg, err := loadRegistry(p, true)
 			if err != nil {
-				return fmt.Errorf("failed to create temp file: %w", err)
+				return fmt.Errorf("failed to load registry: %w", err)
 			}
-			tmpPath := tmpFile.Name()
-			defer os.Remove(tmpPath)
 
-			resp, err := http.Get(downloadURL)
+			outdated, err := findOutdated(inst.State, reg, args, ignorePins)
 			if err != nil {
-				return fmt.Errorf("failed to download: %w", err)
+				return err
 			}
-			defer resp.Body.Close()
 
-			if resp.StatusCode != http.StatusOK {
-				return fmt.Errorf("download failed: %s", resp.Status)
+			if len(outdated) == 0 {
+				fmt.Println("All packages are up to date.")
+				return nil
 			}
 
-			if _, err := io.Copy(tmpFile, resp.Body); err != nil {
-				tmpFile.Close()
-				return fmt.Errorf("failed to write update: %w", err)
+			// Download every upgrade up front; failures are retried and
+			// reported per package below
+			var manifests []*manifest.Manifest
+			for _, o := range outdated {
+				if !o.held() {
+					manifests = append(manifests, o.Manifest)
+				}
+			}
+			if err := inst.Prefetch(manifests, jobs); err != nil {
+				fmt.Printf("Warning: %v\n", err)
 			}
-			tmpFile.Close()
 
-			// Make executable
-			if err := os.Chmod(tmpPath, 0755); err != nil {
-				return fmt.Errorf("failed to set permissions: %w", err)
+			results := make([]string, len(outdated))
+			failed := 0
+			opts := installOptions{jobs: jobs, ignorePins: ignorePins, upgrade: true}
+			for idx, o := range outdated {
+				if o.HeldBy != "" {
+					results[idx] = "held (pinned to " + o.HeldBy + ")"
+					continue
+				}
+				if o.RolledBack {
+					results[idx] = "rolled back (run 'gbpm switch " + o.Name + " " + o.Latest + "')"
+					continue
+				}
+				if err := installWithDependencies(inst, reg, []*manifest.Manifest{o.Manifest}, opts); err != nil {
+					fmt.Printf("Error: failed to upgrade %s: %v\n", o.Name, err)
+					results[idx] = "failed"
+					failed++
+					continue
+				}
+				results[idx] = "upgraded"
 			}
 
-			// Backup current binary
-			backupPath := exePath + ".bak"
-			if err := os.Rename(exePath, backupPath); err != nil {
-				return fmt.Errorf("failed to backup current binary: %w", err)
+			fmt.Println()
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tOLD\tNEW\tSTATUS")
+			for idx, o := range outdated {
+				fmt.Fprintf(w, "%s\tv%s\tv%s\t%s\n", o.Name, o.Current, o.Latest, results[idx])
+			}
+			if err := w.Flush(); err != nil {
+				return err
 			}
 
-		// Move new binary into place
-		if err := os.Rename(tmpPath, exePath); err != nil {
-			// Restore backup on failure
-			_ = os.Rename(backupPath, exePath)
-			return fmt.Errorf("failed to install update: %w", err)
-		}
-
-		// Remove backup
-		_ = os.Remove(backupPath)
-		fmt.Printf("✓ Successfully upgraded to %s\n", latestVersion)
-		fmt.Println("\nRun 'gbpm version' to verify.")
-		return nil
+			if failed > 0 {
+				return fmt.Errorf("%d of %d package(s) failed to upgrade", failed, len(outdated))
+			}
+			return nil
 		},
 	}
-}
-
-func getLatestVersion() (string, error) {
-	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/latest", repoOwner, repoName)
-	
-	resp, err := http.Get(apiURL)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API request failed: %s", resp.Status)
-	}
+	cmd.Flags().BoolVarP(&all, "all", "a", false, "Upgrade all outdated packages")
+	cmd.Flags().BoolVar(&ignorePins, "ignore-pins", false, "Upgrade pinned packages too")
+	cmd.Flags().IntVarP(&jobs, "jobs", "j", defaultJobs(), "Maximum number of concurrent downloads")
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-
-	// Simple JSON parsing for tag_name
-	bodyStr := string(body)
-	tagStart := strings.Index(bodyStr, `"tag_name":`)
-	if tagStart == -1 {
-		return "", fmt.Errorf("could not find tag_name in response")
-	}
-	
-	tagStart += len(`"tag_name":"`)
-	tagEnd := strings.Index(bodyStr[tagStart:], `"`)
-	if tagEnd == -1 {
-		return "", fmt.Errorf("could not parse tag_name")
-	}
-
-	return bodyStr[tagStart : tagStart+tagEnd], nil
-}
-
-func getBinaryName() string {
-	os := runtime.GOOS
-	arch := runtime.GOARCH
-	
-	if os == "windows" {
-		return fmt.Sprintf("gbpm-%s-%s.exe", os, arch)
-	}
-	return fmt.Sprintf("gbpm-%s-%s", os, arch)
+	return cmd
 }
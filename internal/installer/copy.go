@@ -0,0 +1,144 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// expandCopy lists the files a copy step installs:
+//
+//   - a single file is copied to to
+//   - a directory is copied recursively, its contents ending up in to
+//   - a glob copies every match into the directory to, keeping directory
+//     matches whole; a glob with one match is copied to to itself unless to
+//     ends in a slash
+//
+// Symlinks are followed, so their targets are installed as regular files.
+func expandCopy(from, to string) ([]stagedFile, error) {
+	toDir := strings.HasSuffix(to, "/") || strings.HasSuffix(to, `\`)
+	to = filepath.Clean(to)
+
+	sources := []string{from}
+	if isGlob(from) {
+		matches, err := filepath.Glob(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", from, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %s", from)
+		}
+		sort.Strings(matches)
+		sources = matches
+		if len(matches) > 1 {
+			toDir = true
+		}
+	}
+
+	var files []stagedFile
+	for _, src := range sources {
+		info, err := os.Stat(src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open source: %w", err)
+		}
+
+		dest := to
+		if toDir && (len(sources) > 1 || !info.IsDir() || isGlob(from)) {
+			dest = filepath.Join(to, filepath.Base(src))
+		}
+
+		if !info.IsDir() {
+			files = append(files, stagedFile{src: src, dest: dest})
+			continue
+		}
+
+		tree, err := expandDir(src, dest, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, tree...)
+	}
+
+	// Two sources must not land on the same file
+	seen := make(map[string]string)
+	for _, f := range files {
+		if other, ok := seen[f.dest]; ok {
+			return nil, fmt.Errorf("both %s and %s would be installed to %s", other, f.src, f.dest)
+		}
+		seen[f.dest] = f.src
+	}
+
+	return files, nil
+}
+
+// expandDir lists the files below dir, mapped to the same paths below dest.
+// visited holds the resolved directories already walked, so symlink loops
+// are not followed forever.
+func expandDir(dir, dest string, visited map[string]bool) ([]stagedFile, error) {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source: %w", err)
+	}
+	if visited[real] {
+		return nil, nil
+	}
+	visited[real] = true
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	var files []stagedFile
+	for _, entry := range entries {
+		src := filepath.Join(dir, entry.Name())
+		target := filepath.Join(dest, entry.Name())
+
+		info, err := os.Stat(src)
+		if err != nil {
+			// Dangling symlink
+			fmt.Printf("Warning: skipping %s: %v\n", src, err)
+			continue
+		}
+
+		switch {
+		case info.IsDir():
+			tree, err := expandDir(src, target, visited)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, tree...)
+		case info.Mode().IsRegular():
+			files = append(files, stagedFile{src: src, dest: target})
+		}
+	}
+
+	return files, nil
+}
+
+// isGlob reports whether path contains glob metacharacters
+func isGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// removeEmptyDirs removes the directories above file that are left empty,
+// up to but not including the nearest of roots above it
+func removeEmptyDirs(file string, roots ...string) {
+	for dir := filepath.Dir(file); ; dir = filepath.Dir(dir) {
+		inside := false
+		for _, root := range roots {
+			root = filepath.Clean(root)
+			if dir == root {
+				return
+			}
+			if strings.HasPrefix(dir, root+string(os.PathSeparator)) {
+				inside = true
+			}
+		}
+		if !inside || os.Remove(dir) != nil {
+			return
+		}
+	}
+}
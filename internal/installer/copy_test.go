@@ -0,0 +1,218 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// copySource creates the tree copy steps are tested against
+func copySource(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"src/tool.exe":         "tool",
+		"src/bin/a":            "a",
+		"src/bin/b":            "b",
+		"src/bin/sub/c":        "c",
+		"src/share/doc/readme": "readme",
+		"src/one/only":         "only",
+		"src/two/only":         "only",
+	})
+	return root
+}
+
+// copyPlan formats staged files as "src -> dest" relative to root
+func copyPlan(root string, files []stagedFile) string {
+	rel := func(path string) string {
+		r, err := filepath.Rel(root, path)
+		if err != nil {
+			return path
+		}
+		return filepath.ToSlash(r)
+	}
+
+	var plan []string
+	for _, f := range files {
+		plan = append(plan, rel(f.src)+" -> "+rel(f.dest))
+	}
+	return strings.Join(plan, ", ")
+}
+
+func TestExpandCopy(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		want    string
+		wantErr string
+	}{
+		{name: "file", from: "src/tool.exe", to: "out/x.exe", want: "src/tool.exe -> out/x.exe"},
+		{name: "file into directory", from: "src/tool.exe", to: "out/", want: "src/tool.exe -> out/tool.exe"},
+		{
+			name: "directory",
+			from: "src/bin", to: "out/x",
+			want: "src/bin/a -> out/x/a, src/bin/b -> out/x/b, src/bin/sub/c -> out/x/sub/c",
+		},
+		{
+			name: "directory with trailing slash",
+			from: "src/bin", to: "out/x/",
+			want: "src/bin/a -> out/x/a, src/bin/b -> out/x/b, src/bin/sub/c -> out/x/sub/c",
+		},
+		{
+			name: "glob",
+			from: "src/bin/*", to: "out/x",
+			want: "src/bin/a -> out/x/a, src/bin/b -> out/x/b, src/bin/sub/c -> out/x/sub/c",
+		},
+		{name: "glob matching one file", from: "src/one/*", to: "out/x", want: "src/one/only -> out/x"},
+		{name: "glob matching one file with trailing slash", from: "src/one/*", to: "out/x/", want: "src/one/only -> out/x/only"},
+		{name: "glob matching one directory", from: "src/sh*", to: "out/x", want: "src/share/doc/readme -> out/x/doc/readme"},
+		{
+			name: "glob matching one directory with trailing slash",
+			from: "src/sh*", to: "out/x/",
+			want: "src/share/doc/readme -> out/x/share/doc/readme",
+		},
+		{name: "glob matching nothing", from: "src/*.dll", to: "out", wantErr: "no files match"},
+		{name: "invalid glob", from: "src/[", to: "out", wantErr: "invalid pattern"},
+		{name: "missing source", from: "src/missing.exe", to: "out", wantErr: "failed to open source"},
+		{name: "clashing matches", from: "src/*/only", to: "out", wantErr: "would be installed to"},
+	}
+
+	root := copySource(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			to := filepath.Join(root, tt.to)
+			if strings.HasSuffix(tt.to, "/") {
+				to += "/"
+			}
+
+			files, err := expandCopy(filepath.Join(root, tt.from), to)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %v, want an error mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := copyPlan(root, files); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandCopySymlinks(t *testing.T) {
+	root := copySource(t)
+	links := filepath.Join(root, "src", "links")
+	if err := os.MkdirAll(links, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, target := range map[string]string{
+		"file":     "../tool.exe",
+		"dir":      "../one",
+		"loop":     ".",
+		"dangling": "missing",
+	} {
+		if err := os.Symlink(target, filepath.Join(links, name)); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+
+	files, err := expandCopy(links, filepath.Join(root, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Links are followed, a loop is walked once and a dangling link skipped
+	want := "src/links/dir/only -> out/dir/only, src/links/file -> out/file"
+	if got := copyPlan(root, files); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestRemoveEmptyDirs(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string // created below root before removing the first
+		roots []string
+		want  []string // directories left below root
+	}{
+		{
+			name:  "up to the root",
+			files: []string{"home/a/b/c/file"},
+			roots: []string{"home"},
+			want:  []string{"home"},
+		},
+		{
+			name:  "root with trailing slash",
+			files: []string{"home/a/b/file"},
+			roots: []string{"home/"},
+			want:  []string{"home"},
+		},
+		{
+			name:  "stops at a directory in use",
+			files: []string{"home/a/b/c/file", "home/a/other"},
+			roots: []string{"home"},
+			want:  []string{"home", "home/a"},
+		},
+		{
+			name:  "nearest root",
+			files: []string{"home/apps/tool/bin/file"},
+			roots: []string{"home", "home/apps/tool"},
+			want:  []string{"home", "home/apps", "home/apps/tool"},
+		},
+		{
+			name:  "outside the roots",
+			files: []string{"elsewhere/a/file", "home/x"},
+			roots: []string{"home"},
+			want:  []string{"elsewhere", "elsewhere/a", "home"},
+		},
+		{
+			name:  "root prefix is not a parent",
+			files: []string{"home2/a/file", "home/x"},
+			roots: []string{"home"},
+			want:  []string{"home", "home2", "home2/a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			files := make(map[string]string)
+			for _, f := range tt.files {
+				files[f] = "x"
+			}
+			writeFiles(t, root, files)
+
+			file := filepath.Join(root, tt.files[0])
+			if err := os.Remove(file); err != nil {
+				t.Fatal(err)
+			}
+			var roots []string
+			for _, r := range tt.roots {
+				dir := filepath.Join(root, r)
+				if strings.HasSuffix(r, "/") {
+					dir += string(os.PathSeparator)
+				}
+				roots = append(roots, dir)
+			}
+			removeEmptyDirs(file, roots...)
+
+			var dirs []string
+			filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+				if err == nil && d.IsDir() && path != root {
+					rel, _ := filepath.Rel(root, path)
+					dirs = append(dirs, filepath.ToSlash(rel))
+				}
+				return nil
+			})
+			if got, want := strings.Join(dirs, " "), strings.Join(tt.want, " "); got != want {
+				t.Errorf("left %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -2,18 +2,25 @@ package installer
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
 
+	"gopkg.in/yaml.v3"
+
 	"github.com/Foggy-Forge/git-bash-package-manager/internal/manifest"
 	"github.com/Foggy-Forge/git-bash-package-manager/internal/paths"
 	"github.com/Foggy-Forge/git-bash-package-manager/internal/state"
 	"github.com/Foggy-Forge/git-bash-package-manager/internal/util"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/version"
 )
 
 // Installer handles package installation
@@ -21,8 +28,24 @@ type Installer struct {
 	Paths     *paths.Paths
 	State     *state.State
 	StatePath string
+
+	// RequireChecksum refuses to install packages whose platform has no checksum
+	RequireChecksum bool
+
+	// KeepPrevious keeps the installed version as an inactive version when a
+	// different one is installed, marking both versions as kept so they are
+	// never pruned
+	KeepPrevious bool
+
+	// KeepVersions is how many previous versions are retained for rollback
+	// when a package is upgraded; 0 replaces the previous version outright
+	KeepVersions int
 }
 
+// DefaultKeepVersions is the number of previous versions retained for
+// rollback unless GBPM_KEEP_VERSIONS says otherwise
+const DefaultKeepVersions = 1
+
 // New creates a new installer
 func New(p *paths.Paths, statePath string) (*Installer, error) {
 	s, err := state.Load(statePath)
@@ -30,24 +53,66 @@ func New(p *paths.Paths, statePath string) (*Installer, error) {
 		return nil, err
 	}
 
+	keepVersions := DefaultKeepVersions
+	if v := os.Getenv("GBPM_KEEP_VERSIONS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid GBPM_KEEP_VERSIONS %q: must be a number of versions", v)
+		}
+		keepVersions = n
+	}
+
 	return &Installer{
-		Paths:     p,
-		State:     s,
-		StatePath: statePath,
+		Paths:           p,
+		State:           s,
+		StatePath:       statePath,
+		RequireChecksum: os.Getenv("GBPM_REQUIRE_CHECKSUM") == "1",
+		KeepVersions:    keepVersions,
 	}, nil
 }
 
 // Install installs a package from a manifest
 func (i *Installer) Install(m *manifest.Manifest) error {
+	return i.install(m, false)
+}
+
+// InstallDependency installs a package that is only needed by other packages
+func (i *Installer) InstallDependency(m *manifest.Manifest) error {
+	return i.install(m, true)
+}
+
+// Upgrade installs a new version of a package, keeping it marked as a
+// dependency if it was installed as one
+func (i *Installer) Upgrade(m *manifest.Manifest) error {
+	asDependency := false
+	if pkg, ok := i.State.GetPackage(m.Name); ok {
+		asDependency = pkg.AsDependency
+	}
+	return i.install(m, asDependency)
+}
+
+func (i *Installer) install(m *manifest.Manifest, asDependency bool) error {
 	fmt.Printf("Installing %s v%s...\n", m.Name, m.Version)
 
 	// Check if already installed
-	if i.State.IsInstalled(m.Name) {
-		existing, _ := i.State.GetPackage(m.Name)
-		if existing.Version == m.Version {
-			return fmt.Errorf("package %s v%s is already installed", m.Name, m.Version)
+	existing, upgrading := i.State.GetPackage(m.Name)
+	if upgrading {
+		if _, ok := existing.InactiveVersion(m.Version); ok {
+			return fmt.Errorf("package %s v%s is already installed but inactive, use 'gbpm switch %s %s'",
+				m.Name, m.Version, m.Name, m.Version)
+		}
+
+		switch version.Compare(m.Version, existing.Version) {
+		case 0:
+			if existing.AsDependency && !asDependency {
+				return i.markExplicit(existing)
+			}
+			return fmt.Errorf("package %s v%s is already installed", m.Name, existing.Version)
+		case 1:
+			fmt.Printf("Upgrading from v%s to v%s\n", existing.Version, m.Version)
+		default:
+			fmt.Printf("Downgrading from v%s to v%s\n", existing.Version, m.Version)
 		}
-		fmt.Printf("Upgrading from v%s to v%s\n", existing.Version, m.Version)
 	}
 
 	// Get platform
@@ -56,26 +121,31 @@ func (i *Installer) Install(m *manifest.Manifest) error {
 		return err
 	}
 
-	// Download asset
-	cacheDir := filepath.Join(i.Paths.Cache, m.Name, m.Version)
-	
-	// Determine filename - use archive extension if platform says it's an archive
-	filename := filepath.Base(platform.URL)
-	if platform.Archive && !hasArchiveExtension(filename) {
-		// SourceForge and similar may not have extension in URL
-		// Default to .zip for Windows archives
-		filename = m.Name + "-" + m.Version + ".zip"
+	if platform.Checksum == "" && i.RequireChecksum {
+		return fmt.Errorf("package %s v%s has no checksum for %s/%s and checksums are required",
+			m.Name, m.Version, platform.OS, platform.Arch)
 	}
-	
-	cachePath := filepath.Join(cacheDir, filename)
 
+	// Download asset
+	cachePath := i.cachePath(m, platform)
+
+	// Partial downloads live in a .part file next to cachePath and are resumed,
+	// so anything at cachePath itself is a complete download
 	if _, err := os.Stat(cachePath); os.IsNotExist(err) {
-		fmt.Printf("Downloading from %s...\n", platform.URL)
-		if err := util.DownloadWithProgress(platform.URL, cachePath); err != nil {
+		fmt.Printf("Downloading from %s...\n", util.RedactURL(platform.URL))
+		if err := util.DownloadVerified(platform.URL, cachePath, platform.Checksum); err != nil {
 			return fmt.Errorf("failed to download: %w", err)
 		}
+		if platform.Checksum == "" {
+			fmt.Println("Warning: no checksum in manifest, skipping verification")
+		} else {
+			fmt.Println("✓ Checksum verified")
+		}
 	} else {
 		fmt.Println("Using cached download...")
+		if err := verifyChecksum(cachePath, platform.Checksum); err != nil {
+			return err
+		}
 	}
 
 	// Create temp directory for extraction
@@ -85,12 +155,20 @@ func (i *Installer) Install(m *manifest.Manifest) error {
 	}
 	defer os.RemoveAll(tmpDir)
 
+	// Stage installed files so nothing lands in BinDir until every step succeeds
+	txn, err := newTransaction(filepath.Join(i.Paths.Home, "tmp"))
+	if err != nil {
+		return err
+	}
+	defer txn.cleanup()
+
 	// Template context
 	ctx := map[string]string{
 		"TmpDir":   tmpDir,
 		"BinDir":   i.Paths.Bin,
 		"Home":     i.Paths.Home,
 		"CacheDir": i.Paths.Cache,
+		"AppDir":   i.Paths.AppDir(m.Name, m.Version),
 	}
 
 	// Track installed files
@@ -108,9 +186,37 @@ func (i *Installer) Install(m *manifest.Manifest) error {
 			}
 			
 			if platform.Archive {
-				if err := util.Extract(cachePath, to); err != nil {
+				opts := util.ExtractOptions{
+					StripComponents: step.StripComponents,
+					Include:         step.Include,
+					Exclude:         step.Exclude,
+				}
+
+				// Extracting anywhere but TmpDir installs files, so extract to
+				// scratch and stage them like a copy step
+				dest := to
+				if filepath.Clean(to) != filepath.Clean(tmpDir) && !isUnder(to, tmpDir) {
+					dest = filepath.Join(tmpDir, "extract", strconv.Itoa(idx))
+				}
+
+				if err := util.ExtractWith(cachePath, dest, opts); err != nil {
 					return fmt.Errorf("failed to extract: %w", err)
 				}
+
+				if dest != to {
+					files, err := expandCopy(dest, to+string(os.PathSeparator))
+					if err != nil {
+						return fmt.Errorf("failed to extract: %w", err)
+					}
+					for _, f := range files {
+						if err := txn.stage(f.src, f.dest); err != nil {
+							return fmt.Errorf("failed to extract: %w", err)
+						}
+						if !containsPath(installedFiles, f.dest) {
+							installedFiles = append(installedFiles, f.dest)
+						}
+					}
+				}
 			}
 
 		case "copy":
@@ -124,49 +230,200 @@ func (i *Installer) Install(m *manifest.Manifest) error {
 				return fmt.Errorf("failed to render to template: %w", err)
 			}
 
-			if err := copyFile(from, to); err != nil {
+			files, err := expandCopy(from, to)
+			if err != nil {
 				return fmt.Errorf("failed to copy: %w", err)
 			}
-			
-			installedFiles = append(installedFiles, to)
+
+			for _, f := range files {
+				if err := txn.stage(f.src, f.dest); err != nil {
+					return fmt.Errorf("failed to copy: %w", err)
+				}
+				if !containsPath(installedFiles, f.dest) {
+					installedFiles = append(installedFiles, f.dest)
+				}
+			}
+
+		case "shim":
+			target, err := renderTemplate(step.From, ctx)
+			if err != nil {
+				return fmt.Errorf("failed to render from template: %w", err)
+			}
+
+			// The target is usually installed by an earlier step of this package
+			if !containsPath(installedFiles, target) {
+				if _, err := os.Stat(target); err != nil {
+					return fmt.Errorf("shim target %s is not installed by this package", target)
+				}
+			}
+
+			name := shimName(target, step.To)
+			shimDir := filepath.Join(tmpDir, "shims", strconv.Itoa(idx))
+			files, err := writeShims(shimDir, buildShims(name, target, step.Args, m.Name+" v"+m.Version))
+			if err != nil {
+				return err
+			}
+
+			for _, f := range files {
+				dest := filepath.Join(i.Paths.Bin, filepath.Base(f))
+				if err := txn.stage(f, dest); err != nil {
+					return fmt.Errorf("failed to install shim: %w", err)
+				}
+				if !containsPath(installedFiles, dest) {
+					installedFiles = append(installedFiles, dest)
+				}
+			}
 
 		default:
 			return fmt.Errorf("unknown step type: %s", step.Type)
 		}
 	}
 
+	// Retain the manifest with the files so the version can be rolled back to
+	// without the registry
+	data, err := yaml.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	manifestTmp := filepath.Join(tmpDir, manifestFile)
+	if err := os.WriteFile(manifestTmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	manifestDest := i.manifestPath(m.Name, m.Version)
+	if err := txn.stage(manifestTmp, manifestDest); err != nil {
+		return fmt.Errorf("failed to retain manifest: %w", err)
+	}
+	if !containsPath(installedFiles, manifestDest) {
+		installedFiles = append(installedFiles, manifestDest)
+	}
+
+	// The previous version is either retained, with its files outside its app
+	// directory parked, or its files not owned by the new version go away
+	retain := upgrading && (i.KeepPrevious || i.KeepVersions > 0)
+	if retain {
+		for _, f := range i.parkedFiles(existing) {
+			txn.move(f.src, f.dest)
+		}
+		if i.KeepPrevious {
+			fmt.Printf("Keeping v%s installed (inactive)\n", existing.Version)
+		} else {
+			fmt.Printf("Keeping v%s for rollback\n", existing.Version)
+		}
+	} else if upgrading {
+		for _, file := range existing.Files {
+			if !containsPath(installedFiles, file) {
+				txn.remove(file)
+			}
+		}
+	}
+
+	if err := txn.commit(); err != nil {
+		return fmt.Errorf("failed to install files: %w", err)
+	}
+
 	// Update state
+	var dependencies []string
+	for _, dep := range m.Depends {
+		dependencies = append(dependencies, dep.Name)
+	}
+
+	// A package installed explicitly stays explicit when upgraded as a dependency
+	if upgrading && !existing.AsDependency {
+		asDependency = false
+	}
+
 	pkg := &state.Package{
-		Name:        m.Name,
-		Version:     m.Version,
-		Files:       installedFiles,
-		InstalledAt: time.Now(),
+		Name:         m.Name,
+		Version:      m.Version,
+		Registry:     m.Registry,
+		Files:        installedFiles,
+		Dependencies: dependencies,
+		AsDependency: asDependency,
+		InstalledAt:  time.Now(),
+	}
+	var pruned []*state.Package
+	if upgrading {
+		pkg.Inactive = existing.Inactive
+		if retain {
+			previous := deactivated(existing)
+			if i.KeepPrevious {
+				previous.Kept = true
+				pkg.Kept = true
+			}
+			pkg.Inactive = append(append([]*state.Package{}, existing.Inactive...), previous)
+		}
+		pkg.Inactive, pruned = i.prune(pkg.Inactive)
 	}
 	i.State.AddPackage(pkg)
 
 	if err := i.State.Save(i.StatePath); err != nil {
+		txn.rollback()
+		if upgrading {
+			i.State.AddPackage(existing)
+		} else {
+			i.State.RemovePackage(m.Name)
+		}
 		return fmt.Errorf("failed to save state: %w", err)
 	}
 
+	if upgrading && !retain {
+		for _, file := range existing.Files {
+			removeEmptyDirs(file, i.Paths.Bin, i.Paths.Home)
+		}
+	}
+	for _, v := range pruned {
+		i.removeVersion(v)
+		fmt.Printf("Removed retained v%s (keeping %d for rollback)\n", v.Version, i.KeepVersions)
+	}
+
 	fmt.Printf("✓ Successfully installed %s v%s\n", m.Name, m.Version)
 	return nil
 }
 
-// Uninstall uninstalls a package
-func (i *Installer) Uninstall(name string) error {
+// markExplicit records that a package installed as a dependency was asked
+// for explicitly, so autoremove keeps it when nothing depends on it anymore
+func (i *Installer) markExplicit(pkg *state.Package) error {
+	pkg.AsDependency = false
+	if err := i.State.Save(i.StatePath); err != nil {
+		pkg.AsDependency = true
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	fmt.Printf("✓ %s v%s is already installed, marked as explicitly installed\n", pkg.Name, pkg.Version)
+	return nil
+}
+
+// Uninstall uninstalls a package. Packages that other installed packages
+// depend on are refused unless force is set.
+func (i *Installer) Uninstall(name string, force bool) error {
 	pkg, ok := i.State.GetPackage(name)
 	if !ok {
 		return fmt.Errorf("package %s is not installed", name)
 	}
 
+	if dependents := i.State.Dependents(name); len(dependents) > 0 {
+		if !force {
+			return fmt.Errorf("package %s is required by %s (use --force to uninstall anyway)",
+				name, strings.Join(dependents, ", "))
+		}
+		fmt.Printf("Warning: %s is required by %s\n", name, strings.Join(dependents, ", "))
+	}
+
 	fmt.Printf("Uninstalling %s v%s...\n", pkg.Name, pkg.Version)
 
-	// Remove files
-	for _, file := range pkg.Files {
+	// Remove files, including those of inactive versions
+	files := pkg.Files
+	for _, v := range pkg.Inactive {
+		fmt.Printf("Uninstalling inactive v%s\n", v.Version)
+		files = append(append([]string{}, files...), i.inactiveFiles(v)...)
+	}
+
+	for _, file := range files {
 		fmt.Printf("Removing %s\n", file)
 		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
 			fmt.Printf("Warning: failed to remove %s: %v\n", file, err)
 		}
+		removeEmptyDirs(file, i.Paths.Bin, i.Paths.Home)
 	}
 
 	// Remove from state
@@ -179,6 +436,38 @@ func (i *Installer) Uninstall(name string) error {
 	return nil
 }
 
+// cachePath returns where the asset for platform is cached
+func (i *Installer) cachePath(m *manifest.Manifest, platform *manifest.Platform) string {
+	cacheDir := filepath.Join(i.Paths.Cache, m.Name, m.Version)
+	return filepath.Join(cacheDir, assetFilename(m, platform))
+}
+
+// assetFilename picks the cache file name for a platform's asset. Archive
+// formats are detected from the contents when extracting, so the name only
+// needs to be readable; the extension is kept where the URL has one.
+func assetFilename(m *manifest.Manifest, platform *manifest.Platform) string {
+	urlPath := platform.URL
+	if u, err := url.Parse(platform.URL); err == nil && u.Path != "" {
+		urlPath = u.Path
+	}
+
+	filename := path.Base(urlPath)
+	if !platform.Archive || util.HasArchiveExtension(filename) {
+		return filename
+	}
+
+	// SourceForge and similar serve files from URLs like
+	// .../files/tool-1.0.tar.xz/download
+	segments := strings.Split(strings.Trim(urlPath, "/"), "/")
+	for idx := len(segments) - 2; idx >= 0; idx-- {
+		if util.HasArchiveExtension(segments[idx]) {
+			return segments[idx]
+		}
+	}
+
+	return m.Name + "-" + m.Version
+}
+
 // renderTemplate renders a template string with the given context
 func renderTemplate(tmpl string, ctx map[string]string) (string, error) {
 	t, err := template.New("step").Parse(tmpl)
@@ -233,8 +522,36 @@ func copyFile(src, dst string) error {
 	return nil
 }
 
-// hasArchiveExtension checks if a filename has a known archive extension
-func hasArchiveExtension(filename string) bool {
-	ext := strings.ToLower(filepath.Ext(filename))
-	return ext == ".zip" || ext == ".tar" || ext == ".gz" || ext == ".tgz"
+// verifyChecksum verifies a cached download against the manifest checksum,
+// removing it from the cache if it does not match
+func verifyChecksum(path, checksum string) error {
+	if checksum == "" {
+		fmt.Println("Warning: no checksum in manifest, skipping verification")
+		return nil
+	}
+
+	if err := util.VerifyChecksum(path, checksum); err != nil {
+		var mismatch *util.ChecksumMismatchError
+		if errors.As(err, &mismatch) {
+			if rmErr := os.Remove(path); rmErr != nil && !os.IsNotExist(rmErr) {
+				fmt.Printf("Warning: failed to remove %s: %v\n", path, rmErr)
+			}
+			return fmt.Errorf("%w\n\nThe cached file has been removed; run the install again to re-download it", err)
+		}
+		return fmt.Errorf("failed to verify checksum: %w", err)
+	}
+
+	fmt.Println("✓ Checksum verified")
+	return nil
+}
+
+// containsPath reports whether paths contains path, ignoring separator differences
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if filepath.Clean(p) == filepath.Clean(path) {
+			return true
+		}
+	}
+	return false
 }
+
@@ -0,0 +1,276 @@
+package installer
+
+import (
+	"archive/tar"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/manifest"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/paths"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/state"
+)
+
+// defaultSteps installs a package into its app directory with a shim in
+// BinDir, the layout most registry manifests use
+const defaultSteps = `
+    - type: extract
+      to: "{{ .AppDir }}"
+    - type: shim
+      from: "{{ .AppDir }}/bin/{{ .Name }}"`
+
+// newTestInstaller returns an installer for an empty GBPM_HOME
+func newTestInstaller(t *testing.T) *Installer {
+	t.Helper()
+
+	home := t.TempDir()
+	p := &paths.Paths{
+		Home:     home,
+		Bin:      filepath.Join(home, "bin"),
+		Cache:    filepath.Join(home, "cache"),
+		Registry: filepath.Join(home, "registry"),
+		Apps:     filepath.Join(home, "apps"),
+	}
+
+	inst, err := New(p, filepath.Join(home, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst.KeepVersions = DefaultKeepVersions
+	return inst
+}
+
+// testManifest returns a manifest for name and ver running steps (with
+// {{ .Name }} replaced), and puts its archive in the cache so installing it
+// needs no network
+func testManifest(t *testing.T, inst *Installer, name, ver, steps string, depends ...string) *manifest.Manifest {
+	t.Helper()
+
+	m, err := manifest.Parse([]byte(manifestYAML(name, ver, steps, depends...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cacheArchive(t, inst, m)
+	return m
+}
+
+// manifestYAML returns the manifest of a test package for the current
+// platform, with dependencies such as "lib" or "lib >=2"
+func manifestYAML(name, ver, steps string, depends ...string) string {
+	var deps string
+	for _, dep := range depends {
+		deps += fmt.Sprintf("\n  - %q", dep)
+	}
+	if deps != "" {
+		deps = "\ndepends:" + deps
+	}
+
+	return fmt.Sprintf(`name: %s
+version: %q
+platforms:
+  - os: %s
+    arch: %s
+    archive: true
+    url: https://example.invalid/%s-%s.tar%s
+install:
+  steps:%s
+`, name, ver, runtime.GOOS, runtime.GOARCH, name, ver, deps, strings.ReplaceAll(steps, "{{ .Name }}", name))
+}
+
+// cacheArchive puts the archive of a test package in the cache, holding
+// bin/<name>, which prints the version
+func cacheArchive(t *testing.T, inst *Installer, m *manifest.Manifest) {
+	t.Helper()
+
+	platform, err := m.GetPlatform()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestArchive(t, inst.cachePath(m, platform), map[string]string{
+		"bin/" + m.Name: "#!/bin/sh\necho " + m.Name + " " + m.Version + "\n",
+	})
+}
+
+// writeTestArchive writes a tarball of executable files to path
+func writeTestArchive(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	for name, body := range files {
+		h := &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0755, Size: int64(len(body))}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readFile returns the contents of path, or "" if it cannot be read
+func readFile(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// exists reports whether path exists
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func TestInstall(t *testing.T) {
+	inst := newTestInstaller(t)
+	m := testManifest(t, inst, "tool", "1.0", defaultSteps)
+
+	if err := inst.Install(m); err != nil {
+		t.Fatal(err)
+	}
+
+	pkg, ok := inst.State.GetPackage("tool")
+	if !ok {
+		t.Fatal("tool is not recorded as installed")
+	}
+	for _, file := range []string{
+		filepath.Join(inst.Paths.AppDir("tool", "1.0"), "bin", "tool"),
+		filepath.Join(inst.Paths.Bin, "tool"),
+		inst.manifestPath("tool", "1.0"),
+	} {
+		if !exists(file) {
+			t.Errorf("%s was not installed", file)
+		}
+		if !containsPath(pkg.Files, file) {
+			t.Errorf("%s is not tracked in the state", file)
+		}
+	}
+
+	if err := inst.Install(m); err == nil || !strings.Contains(err.Error(), "already installed") {
+		t.Errorf("reinstalling: got %v", err)
+	}
+
+	if err := inst.Uninstall("tool", false); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range pkg.Files {
+		if exists(file) {
+			t.Errorf("%s was not removed on uninstall", file)
+		}
+	}
+}
+
+func TestInstallFailedStep(t *testing.T) {
+	inst := newTestInstaller(t)
+	m := testManifest(t, inst, "tool", "1.0", defaultSteps+`
+    - type: shim
+      from: "{{ .AppDir }}/bin/missing"`)
+
+	if err := inst.Install(m); err == nil {
+		t.Fatal("install with a failing step succeeded")
+	}
+
+	if inst.State.IsInstalled("tool") {
+		t.Error("failed install recorded in the state")
+	}
+	for _, path := range []string{filepath.Join(inst.Paths.Bin, "tool"), inst.Paths.AppDir("tool", "1.0")} {
+		if exists(path) {
+			t.Errorf("failed install left %s behind", path)
+		}
+	}
+	if entries, _ := os.ReadDir(filepath.Join(inst.Paths.Home, "tmp")); len(entries) > 0 {
+		t.Errorf("failed install left %d staging director(ies) behind", len(entries))
+	}
+}
+
+func TestUpgradeFailedCommit(t *testing.T) {
+	inst := newTestInstaller(t)
+	inst.KeepVersions = 0
+
+	if err := inst.Install(testManifest(t, inst, "tool", "1.0", defaultSteps)); err != nil {
+		t.Fatal(err)
+	}
+	shim := filepath.Join(inst.Paths.Bin, "tool")
+	oldShim := readFile(shim)
+	oldBinary := filepath.Join(inst.Paths.AppDir("tool", "1.0"), "bin", "tool")
+
+	// The last file cannot be moved into place, after the shim has been
+	// replaced: its directory is a file
+	if err := os.WriteFile(filepath.Join(inst.Paths.Bin, "blocker"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := testManifest(t, inst, "tool", "2.0", defaultSteps+`
+    - type: extract
+      to: "{{ .TmpDir }}/x"
+    - type: copy
+      from: "{{ .TmpDir }}/x/bin/tool"
+      to: "{{ .BinDir }}/blocker/tool"`)
+
+	if err := inst.Upgrade(m); err == nil || !strings.Contains(err.Error(), "failed to install files") {
+		t.Fatalf("got %v, want the commit to fail", err)
+	}
+
+	if pkg, _ := inst.State.GetPackage("tool"); pkg.Version != "1.0" {
+		t.Errorf("state records v%s after a failed upgrade, want v1.0", pkg.Version)
+	}
+	if got := readFile(shim); got != oldShim {
+		t.Errorf("replaced shim not restored:\n%s", got)
+	}
+	if !exists(oldBinary) {
+		t.Error("files of the previous version were removed")
+	}
+	if exists(filepath.Join(inst.Paths.AppDir("tool", "2.0"), "bin", "tool")) {
+		t.Error("staged files of the failed upgrade were left behind")
+	}
+}
+
+func TestInstallPromotesDependency(t *testing.T) {
+	inst := newTestInstaller(t)
+	m := testManifest(t, inst, "lib", "1.0", defaultSteps)
+
+	if err := inst.InstallDependency(m); err != nil {
+		t.Fatal(err)
+	}
+	if orphans := inst.State.Orphans(); len(orphans) != 1 || orphans[0] != "lib" {
+		t.Fatalf("Orphans() = %v, want [lib]", orphans)
+	}
+
+	// Asking for it explicitly keeps it from being autoremoved
+	if err := inst.Install(m); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := state.Load(inst.StatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg, _ := saved.GetPackage("lib"); pkg.AsDependency {
+		t.Error("lib is still marked as a dependency")
+	}
+	if orphans := saved.Orphans(); len(orphans) != 0 {
+		t.Errorf("Orphans() = %v, want none", orphans)
+	}
+
+	// Installing it as a dependency again does not demote it
+	if err := inst.InstallDependency(m); err == nil || !strings.Contains(err.Error(), "already installed") {
+		t.Errorf("reinstalling as a dependency: got %v", err)
+	}
+	if pkg, _ := inst.State.GetPackage("lib"); pkg.AsDependency {
+		t.Error("lib was demoted to a dependency")
+	}
+}
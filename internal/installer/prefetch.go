@@ -0,0 +1,113 @@
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/manifest"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/util"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/version"
+)
+
+// DefaultJobs is the number of concurrent downloads used by Prefetch when
+// no limit is given
+const DefaultJobs = 4
+
+// Prefetch downloads the assets of manifests into the cache, up to jobs at
+// a time, showing one progress line per download. Assets that are already
+// cached are skipped; they are verified when installed. A single missing
+// asset is left for the install to download.
+//
+// Prefetch only fills the cache, so the packages can then be installed one
+// after another without any further downloads. Failed downloads keep their
+// partial file and are resumed by the next attempt.
+func (i *Installer) Prefetch(manifests []*manifest.Manifest, jobs int) error {
+	if jobs < 1 {
+		jobs = DefaultJobs
+	}
+
+	type download struct {
+		m         *manifest.Manifest
+		platform  *manifest.Platform
+		cachePath string
+	}
+
+	var pending []download
+	for _, m := range manifests {
+		platform, err := m.GetPlatform()
+		if err != nil {
+			// Reported when the package is installed
+			continue
+		}
+		if platform.Checksum == "" && i.RequireChecksum {
+			continue
+		}
+
+		// Reinstalling the active version only updates the state
+		if pkg, ok := i.State.GetPackage(m.Name); ok && version.Compare(pkg.Version, m.Version) == 0 {
+			continue
+		}
+
+		cachePath := i.cachePath(m, platform)
+		if _, err := os.Stat(cachePath); err == nil {
+			continue
+		}
+		pending = append(pending, download{m, platform, cachePath})
+	}
+
+	// A single download is left to the install, which shows its own progress
+	if len(pending) < 2 {
+		return nil
+	}
+
+	fmt.Printf("Downloading %d packages (%d at a time)...\n", len(pending), jobs)
+
+	progress := util.NewMultiProgress()
+	bars := make([]*util.ProgressBar, len(pending))
+	for idx, d := range pending {
+		bars[idx] = progress.Add(d.m.Name + " v" + d.m.Version)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+		sem  = make(chan struct{}, jobs)
+	)
+	for idx, d := range pending {
+		wg.Add(1)
+		go func(d download, bar *util.ProgressBar) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			err := util.DownloadFile(d.platform.URL, d.cachePath, util.DownloadOptions{
+				Checksum: d.platform.Checksum,
+				Progress: bar.Update,
+				Logf:     bar.Logf,
+			})
+			if err != nil {
+				bar.Done("failed")
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", d.m.Name, err))
+				mu.Unlock()
+				return
+			}
+
+			if d.platform.Checksum == "" {
+				bar.Done("done (no checksum)")
+			} else {
+				bar.Done("done, checksum verified")
+			}
+		}(d, bars[idx])
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to download: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
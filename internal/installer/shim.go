@@ -0,0 +1,94 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// shim is a generated launcher file
+type shim struct {
+	name    string
+	content string
+	mode    os.FileMode
+}
+
+// shimName returns the launcher name for a shim step: to if given,
+// otherwise the target's file name without a Windows executable extension
+func shimName(target, to string) string {
+	if to != "" {
+		return to
+	}
+
+	name := filepath.Base(target)
+	ext := strings.ToLower(filepath.Ext(name))
+	switch ext {
+	case ".exe", ".cmd", ".bat", ".com":
+		return strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return name
+}
+
+// buildShims generates the launchers for target: a bash script for Git Bash,
+// plus a .cmd file for cmd and PowerShell on Windows. Both pass fixed args
+// followed by the caller's arguments, and inherit the caller's environment.
+func buildShims(name, target string, args []string, pkg string) []shim {
+	comment := fmt.Sprintf("Generated by gbpm for %s; do not edit", pkg)
+
+	var sh strings.Builder
+	sh.WriteString("#!/usr/bin/env bash\n")
+	sh.WriteString("# " + comment + "\n")
+	sh.WriteString("exec " + bashQuote(filepath.ToSlash(target)))
+	for _, arg := range args {
+		sh.WriteString(" " + bashQuote(arg))
+	}
+	sh.WriteString(" \"$@\"\n")
+
+	shims := []shim{{name: name, content: sh.String(), mode: 0755}}
+
+	if runtime.GOOS == "windows" {
+		var cmd strings.Builder
+		cmd.WriteString("@echo off\r\n")
+		cmd.WriteString("rem " + comment + "\r\n")
+		cmd.WriteString(cmdQuote(filepath.FromSlash(target)))
+		for _, arg := range args {
+			cmd.WriteString(" " + cmdQuote(arg))
+		}
+		cmd.WriteString(" %*\r\n")
+		cmd.WriteString("exit /b %ERRORLEVEL%\r\n")
+
+		shims = append(shims, shim{name: name + ".cmd", content: cmd.String(), mode: 0755})
+	}
+
+	return shims
+}
+
+// writeShims writes the launchers into dir, returning their paths
+func writeShims(dir string, shims []shim) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	var files []string
+	for _, s := range shims {
+		path := filepath.Join(dir, s.name)
+		if err := os.WriteFile(path, []byte(s.content), s.mode); err != nil {
+			return nil, fmt.Errorf("failed to write shim %s: %w", s.name, err)
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// bashQuote quotes s as a single bash word
+func bashQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// cmdQuote quotes s as a single cmd.exe argument
+func cmdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
@@ -0,0 +1,232 @@
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"syscall"
+)
+
+// transaction stages installed files in a scratch directory and moves them
+// into place in a single commit, so a failed install can be rolled back
+type transaction struct {
+	dir       string
+	staged    []stagedFile
+	removals  []string
+	moves     []stagedFile
+	moved     []stagedFile
+	committed []string
+	backups   map[string]string
+}
+
+type stagedFile struct {
+	src  string
+	dest string
+}
+
+// newTransaction creates a transaction with its scratch directory under root.
+// The scratch directory lives next to the install targets so that commits
+// are usually plain renames on the same filesystem; targets on other volumes
+// are copied.
+func newTransaction(root string) (*transaction, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create staging root: %w", err)
+	}
+
+	dir, err := os.MkdirTemp(root, ".txn-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+
+	for _, sub := range []string{"stage", "backup"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("failed to create staging directory: %w", err)
+		}
+	}
+
+	return &transaction{
+		dir:     dir,
+		backups: make(map[string]string),
+	}, nil
+}
+
+// stage copies src into the scratch directory to be installed at dest on commit
+func (t *transaction) stage(src, dest string) error {
+	stagePath := filepath.Join(t.dir, "stage", strconv.Itoa(len(t.staged)))
+	if err := copyFile(src, stagePath); err != nil {
+		return err
+	}
+
+	t.staged = append(t.staged, stagedFile{src: stagePath, dest: dest})
+	return nil
+}
+
+// remove schedules an existing file to be removed on commit
+func (t *transaction) remove(path string) {
+	t.removals = append(t.removals, path)
+}
+
+// move schedules an existing file to be moved from src to dest on commit,
+// before any staged file is installed. Missing files are skipped.
+func (t *transaction) move(src, dest string) {
+	t.moves = append(t.moves, stagedFile{src: src, dest: dest})
+}
+
+// commit moves all staged files into place and removes scheduled files.
+// Any file that is replaced or removed is backed up first, and on failure
+// every change made so far is rolled back.
+func (t *transaction) commit() error {
+	for _, m := range t.moves {
+		if _, err := os.Lstat(m.src); os.IsNotExist(err) {
+			fmt.Printf("Warning: %s is missing, skipping\n", m.src)
+			continue
+		}
+
+		if err := t.backup(m.dest); err != nil {
+			t.rollback()
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(m.dest), 0755); err != nil {
+			t.rollback()
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := moveFile(m.src, m.dest); err != nil {
+			t.rollback()
+			return fmt.Errorf("failed to move %s: %w", m.src, err)
+		}
+		t.moved = append(t.moved, m)
+	}
+
+	for _, f := range t.staged {
+		if err := t.backup(f.dest); err != nil {
+			t.rollback()
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(f.dest), 0755); err != nil {
+			t.rollback()
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+
+		if err := moveFile(f.src, f.dest); err != nil {
+			t.rollback()
+			return fmt.Errorf("failed to install %s: %w", f.dest, err)
+		}
+		t.committed = append(t.committed, f.dest)
+	}
+
+	for _, path := range t.removals {
+		if err := t.backup(path); err != nil {
+			t.rollback()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rollback undoes a commit, removing newly installed files and restoring
+// any files that were replaced or removed
+func (t *transaction) rollback() {
+	for idx := len(t.committed) - 1; idx >= 0; idx-- {
+		path := t.committed[idx]
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Warning: failed to remove %s: %v\n", path, err)
+		}
+	}
+
+	for idx := len(t.moved) - 1; idx >= 0; idx-- {
+		m := t.moved[idx]
+		if err := moveFile(m.dest, m.src); err != nil {
+			fmt.Printf("Warning: failed to restore %s: %v\n", m.src, err)
+		}
+	}
+
+	for path, backup := range t.backups {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			fmt.Printf("Warning: failed to restore %s: %v\n", path, err)
+			continue
+		}
+		if err := moveFile(backup, path); err != nil {
+			fmt.Printf("Warning: failed to restore %s: %v\n", path, err)
+		}
+	}
+
+	t.committed = nil
+	t.moved = nil
+	t.backups = make(map[string]string)
+}
+
+// cleanup removes the scratch directory and any backups it holds
+func (t *transaction) cleanup() {
+	os.RemoveAll(t.dir)
+}
+
+// backup moves an existing file out of the way, remembering where it went
+func (t *transaction) backup(path string) error {
+	if _, ok := t.backups[path]; ok {
+		return nil
+	}
+
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	backupPath := filepath.Join(t.dir, "backup", strconv.Itoa(len(t.backups)))
+	if err := moveFile(path, backupPath); err != nil {
+		return fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+
+	t.backups[path] = backupPath
+	return nil
+}
+
+// rename is os.Rename, replaced in tests to simulate other volumes
+var rename = os.Rename
+
+// errNotSameDevice is ERROR_NOT_SAME_DEVICE, returned by renames across
+// drives on Windows
+const errNotSameDevice = syscall.Errno(17)
+
+// moveFile renames src to dst, falling back to copying and removing src when
+// they are on different volumes, e.g. a copy step targeting D:\ or another
+// mount
+func moveFile(src, dst string) error {
+	err := rename(src, dst)
+	if err == nil || !isCrossDevice(err) {
+		return err
+	}
+
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		err = os.Symlink(target, dst)
+	} else {
+		err = copyFile(src, dst)
+	}
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// isCrossDevice reports whether err is a rename failing because source and
+// destination are on different volumes
+func isCrossDevice(err error) bool {
+	if errors.Is(err, syscall.EXDEV) {
+		return true
+	}
+	return runtime.GOOS == "windows" && errors.Is(err, errNotSameDevice)
+}
@@ -0,0 +1,165 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// writeFiles creates files below dir with the given contents
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, body := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkFiles checks the contents of files below dir, "" meaning missing
+func checkFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, want := range files {
+		path := filepath.Join(dir, name)
+		if want == "" {
+			if exists(path) {
+				t.Errorf("%s exists, want it removed", name)
+			}
+			continue
+		}
+		if got := readFile(path); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// newTestTransaction returns a transaction in dir that installs new over
+// replaced, removes removed and moves moved to moved-to
+func newTestTransaction(t *testing.T, dir string) *transaction {
+	t.Helper()
+
+	writeFiles(t, dir, map[string]string{
+		"src/new":      "new",
+		"src/replaced": "replacement",
+		"replaced":     "old",
+		"removed":      "removed",
+		"moved":        "moved",
+	})
+
+	txn, err := newTransaction(filepath.Join(dir, "tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(txn.cleanup)
+
+	if err := txn.stage(filepath.Join(dir, "src", "new"), filepath.Join(dir, "dest", "new")); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.stage(filepath.Join(dir, "src", "replaced"), filepath.Join(dir, "replaced")); err != nil {
+		t.Fatal(err)
+	}
+	txn.remove(filepath.Join(dir, "removed"))
+	txn.move(filepath.Join(dir, "moved"), filepath.Join(dir, "parked", "moved"))
+	return txn
+}
+
+func TestTransactionCommit(t *testing.T) {
+	dir := t.TempDir()
+	txn := newTestTransaction(t, dir)
+
+	if err := txn.commit(); err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, dir, map[string]string{
+		"dest/new":     "new",
+		"replaced":     "replacement",
+		"removed":      "",
+		"moved":        "",
+		"parked/moved": "moved",
+	})
+
+	// Rolling back after the state failed to save restores everything
+	txn.rollback()
+	checkFiles(t, dir, map[string]string{
+		"dest/new":     "",
+		"replaced":     "old",
+		"removed":      "removed",
+		"moved":        "moved",
+		"parked/moved": "",
+	})
+}
+
+func TestTransactionFailedCommit(t *testing.T) {
+	dir := t.TempDir()
+	txn := newTestTransaction(t, dir)
+
+	// The last staged file cannot be installed: its directory is a file
+	writeFiles(t, dir, map[string]string{"blocker": "", "src/blocked": "blocked"})
+	if err := txn.stage(filepath.Join(dir, "src", "blocked"), filepath.Join(dir, "blocker", "blocked")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := txn.commit(); err == nil {
+		t.Fatal("commit succeeded")
+	}
+	checkFiles(t, dir, map[string]string{
+		"dest/new":     "",
+		"dest/blocked": "",
+		"replaced":     "old",
+		"removed":      "removed",
+		"moved":        "moved",
+		"parked/moved": "",
+	})
+}
+
+func TestTransactionCrossDevice(t *testing.T) {
+	// Every rename fails as if the scratch directory were on another volume
+	defer func(r func(string, string) error) { rename = r }(rename)
+	rename = func(src, dst string) error {
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: syscall.EXDEV}
+	}
+
+	dir := t.TempDir()
+	txn := newTestTransaction(t, dir)
+
+	if err := txn.commit(); err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, dir, map[string]string{
+		"dest/new":     "new",
+		"replaced":     "replacement",
+		"removed":      "",
+		"moved":        "",
+		"parked/moved": "moved",
+	})
+
+	txn.rollback()
+	checkFiles(t, dir, map[string]string{
+		"dest/new":     "",
+		"replaced":     "old",
+		"removed":      "removed",
+		"moved":        "moved",
+		"parked/moved": "",
+	})
+}
+
+func TestMoveFileOtherErrors(t *testing.T) {
+	defer func(r func(string, string) error) { rename = r }(rename)
+	rename = func(src, dst string) error {
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: syscall.EACCES}
+	}
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"src": "src"})
+
+	// Only cross-device renames fall back to copying
+	if err := moveFile(filepath.Join(dir, "src"), filepath.Join(dir, "dst")); err == nil {
+		t.Fatal("moveFile succeeded despite the rename failing")
+	}
+	checkFiles(t, dir, map[string]string{"src": "src", "dst": ""})
+}
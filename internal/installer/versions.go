@@ -0,0 +1,231 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/manifest"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/state"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/version"
+)
+
+// parkedDir is where an inactive version keeps its files that live outside
+// its app directory, such as launchers and binaries in BinDir
+const parkedDir = ".gbpm-parked"
+
+// manifestFile is the manifest of an installed version, kept in its app
+// directory so the version can be restored without the registry
+const manifestFile = ".gbpm-manifest.yaml"
+
+// Switch makes an installed inactive version of a package the active one.
+// The bin entries of the active version are parked in its app directory and
+// those of the chosen version are moved back into place.
+func (i *Installer) Switch(name, ver string) error {
+	pkg, ok := i.State.GetPackage(name)
+	if !ok {
+		return fmt.Errorf("package %s is not installed", name)
+	}
+
+	if version.Compare(ver, pkg.Version) == 0 {
+		return fmt.Errorf("package %s v%s is already active", name, pkg.Version)
+	}
+
+	target, ok := pkg.InactiveVersion(ver)
+	if !ok {
+		return fmt.Errorf("package %s v%s is not installed (installed: %s)",
+			name, ver, strings.Join(pkg.InstalledVersions(), ", "))
+	}
+
+	fmt.Printf("Switching %s from v%s to v%s...\n", name, pkg.Version, target.Version)
+	return i.activate(pkg, target)
+}
+
+// Rollback makes the previously active version of a package active again,
+// from the files and manifest retained when it was replaced. Rolling back
+// twice returns to the version rolled back from.
+func (i *Installer) Rollback(name string) error {
+	pkg, ok := i.State.GetPackage(name)
+	if !ok {
+		return fmt.Errorf("package %s is not installed", name)
+	}
+
+	if len(pkg.Inactive) == 0 {
+		return fmt.Errorf("package %s has no previous version to roll back to", name)
+	}
+	target := pkg.Inactive[len(pkg.Inactive)-1]
+
+	fmt.Printf("Rolling back %s from v%s to v%s...\n", name, pkg.Version, target.Version)
+	i.checkDependencies(target)
+	return i.activate(pkg, target)
+}
+
+// activate parks the files of the active version of pkg outside its app
+// directory and moves those of target back into place
+func (i *Installer) activate(pkg, target *state.Package) error {
+	txn, err := newTransaction(filepath.Join(i.Paths.Home, "tmp"))
+	if err != nil {
+		return err
+	}
+	defer txn.cleanup()
+
+	for _, f := range i.parkedFiles(pkg) {
+		txn.move(f.src, f.dest)
+	}
+	for _, f := range i.parkedFiles(target) {
+		txn.move(f.dest, f.src)
+	}
+
+	if err := txn.commit(); err != nil {
+		return fmt.Errorf("failed to switch files: %w", err)
+	}
+
+	active := activated(pkg, target)
+	i.State.AddPackage(active)
+
+	if err := i.State.Save(i.StatePath); err != nil {
+		txn.rollback()
+		i.State.AddPackage(pkg)
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	for _, f := range i.parkedFiles(target) {
+		removeEmptyDirs(f.dest, i.Paths.Home)
+	}
+
+	fmt.Printf("✓ %s v%s is now active\n", pkg.Name, target.Version)
+	return nil
+}
+
+// checkDependencies warns about dependencies of an inactive version that the
+// installed packages no longer satisfy, using the manifest retained with it
+func (i *Installer) checkDependencies(pkg *state.Package) {
+	m, err := manifest.LoadManifest(i.manifestPath(pkg.Name, pkg.Version))
+	if err != nil {
+		// Versions installed before manifests were retained have none
+		return
+	}
+
+	for _, dep := range m.Depends {
+		installed, ok := i.State.GetPackage(dep.Name)
+		if !ok {
+			fmt.Printf("Warning: %s v%s requires %s, which is not installed\n", pkg.Name, pkg.Version, dep)
+			continue
+		}
+		if dep.Version == "" {
+			continue
+		}
+		c, err := version.ParseConstraint(dep.Version)
+		if err != nil {
+			continue
+		}
+		if !c.CheckString(installed.Version) {
+			fmt.Printf("Warning: %s v%s requires %s, but v%s is installed\n",
+				pkg.Name, pkg.Version, dep, installed.Version)
+		}
+	}
+}
+
+// prune splits the inactive versions into those retained and those beyond
+// the KeepVersions most recently active ones. Versions kept side by side
+// with --keep are always retained.
+func (i *Installer) prune(versions []*state.Package) (retained, pruned []*state.Package) {
+	keep := i.KeepVersions
+	for idx := len(versions) - 1; idx >= 0; idx-- {
+		v := versions[idx]
+		switch {
+		case v.Kept:
+		case keep > 0:
+			keep--
+		default:
+			pruned = append(pruned, v)
+			continue
+		}
+		retained = append([]*state.Package{v}, retained...)
+	}
+	return retained, pruned
+}
+
+// removeVersion removes the files of an inactive version
+func (i *Installer) removeVersion(pkg *state.Package) {
+	for _, file := range i.inactiveFiles(pkg) {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Warning: failed to remove %s: %v\n", file, err)
+		}
+		removeEmptyDirs(file, i.Paths.Bin, i.Paths.Home)
+	}
+}
+
+// manifestPath returns where the manifest of an installed version is retained
+func (i *Installer) manifestPath(name, ver string) string {
+	return filepath.Join(i.Paths.AppDir(name, ver), manifestFile)
+}
+
+// parkedFiles maps the files of an installed version that live outside its
+// app directory to where they are parked while the version is inactive
+func (i *Installer) parkedFiles(pkg *state.Package) []stagedFile {
+	appDir := i.Paths.AppDir(pkg.Name, pkg.Version)
+
+	var files []stagedFile
+	for idx, file := range pkg.Files {
+		if isUnder(file, appDir) {
+			continue
+		}
+		files = append(files, stagedFile{
+			src:  file,
+			dest: filepath.Join(appDir, parkedDir, strconv.Itoa(idx)),
+		})
+	}
+	return files
+}
+
+// inactiveFiles returns where the files of an inactive version are on disk
+func (i *Installer) inactiveFiles(pkg *state.Package) []string {
+	parked := make(map[string]string)
+	for _, f := range i.parkedFiles(pkg) {
+		parked[f.src] = f.dest
+	}
+
+	var files []string
+	for _, file := range pkg.Files {
+		if p, ok := parked[file]; ok {
+			file = p
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// deactivated returns the state entry of pkg as an inactive version
+func deactivated(pkg *state.Package) *state.Package {
+	v := *pkg
+	v.Inactive = nil
+	return &v
+}
+
+// activated returns the state entry with target as the active version and
+// the previously active version of pkg inactive
+func activated(pkg, target *state.Package) *state.Package {
+	active := *target
+	active.AsDependency = pkg.AsDependency
+
+	for _, v := range pkg.Inactive {
+		if v != target {
+			active.Inactive = append(active.Inactive, v)
+		}
+	}
+	active.Inactive = append(active.Inactive, deactivated(pkg))
+
+	return &active
+}
+
+// isUnder reports whether path is inside dir
+func isUnder(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator)) && !filepath.IsAbs(rel)
+}
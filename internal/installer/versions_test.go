@@ -0,0 +1,388 @@
+package installer
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/manifest"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/registry"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/version"
+)
+
+// testRegistry is a directory registry of test packages
+type testRegistry struct {
+	t    *testing.T
+	inst *Installer
+	dir  string
+	set  *registry.Set
+}
+
+// newTestRegistry creates an empty directory registry for inst
+func newTestRegistry(t *testing.T, inst *Installer) *testRegistry {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "packages"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := registry.Load(inst.Paths.Registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := set.Add("test", dir, 10); err != nil {
+		t.Fatal(err)
+	}
+	return &testRegistry{t: t, inst: inst, dir: dir, set: set}
+}
+
+// add publishes a version of a package, as the latest manifest if it is the
+// highest version so far and under versions/ otherwise
+func (r *testRegistry) add(name, ver, steps string, depends ...string) {
+	r.t.Helper()
+
+	dir := filepath.Join(r.dir, "packages", name)
+	latest := filepath.Join(dir, name+".yaml")
+	data := []byte(manifestYAML(name, ver, steps, depends...))
+
+	target := latest
+	if m, err := manifest.LoadManifest(latest); err == nil {
+		if version.Compare(ver, m.Version) > 0 {
+			old, _ := os.ReadFile(latest)
+			r.write(filepath.Join(dir, "versions", m.Version+".yaml"), old)
+		} else {
+			target = filepath.Join(dir, "versions", ver+".yaml")
+		}
+	}
+	r.write(target, data)
+}
+
+func (r *testRegistry) write(path string, data []byte) {
+	r.t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// get looks up a version of a package and caches its archive
+func (r *testRegistry) get(name, ver string) *manifest.Manifest {
+	r.t.Helper()
+
+	c, err := version.ParseConstraint(ver)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	reg, m, err := r.set.FindManifestVersion(name, c)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	m.Registry = reg.Name
+	cacheArchive(r.t, r.inst, m)
+	return m
+}
+
+// installedVersions returns the active version of a package followed by its
+// inactive versions, e.g. "2.0 [1.0 1.5]", with kept versions marked "+"
+func installedVersions(inst *Installer, name string) string {
+	pkg, ok := inst.State.GetPackage(name)
+	if !ok {
+		return "not installed"
+	}
+
+	format := func(v string, kept bool) string {
+		if kept {
+			return v + "+"
+		}
+		return v
+	}
+
+	var inactive []string
+	for _, v := range pkg.Inactive {
+		inactive = append(inactive, format(v.Version, v.Kept))
+	}
+	return format(pkg.Version, pkg.Kept) + " [" + strings.Join(inactive, " ") + "]"
+}
+
+// captureOutput returns what fn prints to stdout
+func captureOutput(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+
+	fn()
+	w.Close()
+	os.Stdout = stdout
+	return <-done
+}
+
+// shimFor reports whether the shim of a package runs the given version
+func shimFor(inst *Installer, name, ver string) bool {
+	return strings.Contains(readFile(filepath.Join(inst.Paths.Bin, name)), inst.Paths.AppDir(name, ver))
+}
+
+func TestKeepAndSwitch(t *testing.T) {
+	inst := newTestInstaller(t)
+	reg := newTestRegistry(t, inst)
+	reg.add("tool", "1.0", defaultSteps)
+	reg.add("tool", "2.0", defaultSteps)
+
+	if err := inst.Install(reg.get("tool", "1.0")); err != nil {
+		t.Fatal(err)
+	}
+	v1, _ := inst.State.GetPackage("tool")
+
+	inst.KeepPrevious = true
+	if err := inst.Install(reg.get("tool", "2.0")); err != nil {
+		t.Fatal(err)
+	}
+	if got := installedVersions(inst, "tool"); got != "2.0+ [1.0+]" {
+		t.Errorf("installed %s, want 2.0+ [1.0+]", got)
+	}
+	if !shimFor(inst, "tool", "2.0") {
+		t.Error("shim does not run v2.0")
+	}
+
+	// The shim of 1.0 is parked in its app directory
+	for _, f := range inst.parkedFiles(v1) {
+		if !strings.Contains(readFile(f.dest), inst.Paths.AppDir("tool", "1.0")) {
+			t.Errorf("%s is not parked at %s", f.src, f.dest)
+		}
+	}
+
+	if err := inst.Switch("tool", "1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if got := installedVersions(inst, "tool"); got != "1.0+ [2.0+]" {
+		t.Errorf("installed %s after switching, want 1.0+ [2.0+]", got)
+	}
+	if !shimFor(inst, "tool", "1.0") {
+		t.Error("shim does not run v1.0 after switching")
+	}
+	v2, _ := inst.State.GetPackage("tool")
+	for _, f := range inst.parkedFiles(v2.Inactive[0]) {
+		if !exists(f.dest) {
+			t.Errorf("%s of v2.0 is not parked", f.src)
+		}
+	}
+
+	for ver, want := range map[string]string{"1.0": "already active", "3.0": "not installed (installed: 1.0, 2.0)"} {
+		if err := inst.Switch("tool", ver); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("switching to %s: got %v, want %q", ver, err, want)
+		}
+	}
+	if err := inst.Install(reg.get("tool", "2.0")); err == nil || !strings.Contains(err.Error(), "inactive") {
+		t.Errorf("installing an inactive version: got %v", err)
+	}
+
+	// Uninstalling removes every version, parked files included
+	if err := inst.Uninstall("tool", false); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{inst.Paths.Bin, inst.Paths.Apps} {
+		if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+			t.Errorf("%s still holds %d entries", dir, len(entries))
+		}
+	}
+}
+
+func TestUpgradeRetainsVersions(t *testing.T) {
+	tests := []struct {
+		name         string
+		keepVersions int
+		installs     []string // a "+" suffix installs with --keep
+		want         string
+	}{
+		{name: "replace", keepVersions: 0, installs: []string{"1.0", "2.0", "3.0"}, want: "3.0 []"},
+		{name: "keep one", keepVersions: 1, installs: []string{"1.0", "2.0", "3.0"}, want: "3.0 [2.0]"},
+		{name: "keep two", keepVersions: 2, installs: []string{"1.0", "2.0", "3.0", "4.0"}, want: "4.0 [2.0 3.0]"},
+		{name: "downgrade", keepVersions: 1, installs: []string{"3.0", "2.0"}, want: "2.0 [3.0]"},
+		{
+			name:         "kept versions are never pruned",
+			keepVersions: 1,
+			installs:     []string{"1.0", "2.0+", "3.0", "4.0", "5.0"},
+			want:         "5.0 [1.0+ 2.0+ 4.0]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inst := newTestInstaller(t)
+			inst.KeepVersions = tt.keepVersions
+			reg := newTestRegistry(t, inst)
+
+			for _, ver := range tt.installs {
+				ver, keep := strings.CutSuffix(ver, "+")
+				reg.add("tool", ver, defaultSteps)
+				inst.KeepPrevious = keep
+				if err := inst.Install(reg.get("tool", ver)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := installedVersions(inst, "tool"); got != tt.want {
+				t.Errorf("installed %s, want %s", got, tt.want)
+			}
+
+			// Only the active and retained versions are left on disk
+			pkg, _ := inst.State.GetPackage("tool")
+			for _, ver := range tt.installs {
+				ver = strings.TrimSuffix(ver, "+")
+				_, retained := pkg.InactiveVersion(ver)
+				retained = retained || ver == pkg.Version
+				if got := exists(inst.manifestPath("tool", ver)); got != retained {
+					t.Errorf("v%s on disk: %v, want %v", ver, got, retained)
+				}
+			}
+			if !shimFor(inst, "tool", pkg.Version) {
+				t.Errorf("shim does not run the active v%s", pkg.Version)
+			}
+		})
+	}
+}
+
+func TestUninstallDependency(t *testing.T) {
+	inst := newTestInstaller(t)
+	reg := newTestRegistry(t, inst)
+	reg.add("lib", "1.0", defaultSteps)
+	reg.add("lib", "2.0", defaultSteps)
+	reg.add("app", "1.0", defaultSteps, "lib")
+
+	for _, m := range []*manifest.Manifest{reg.get("lib", "1.0"), reg.get("lib", "2.0")} {
+		if err := inst.InstallDependency(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := inst.Install(reg.get("app", "1.0")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := inst.Uninstall("lib", false); err == nil || !strings.Contains(err.Error(), "required by app") {
+		t.Fatalf("uninstalling a dependency: got %v", err)
+	}
+	if got := installedVersions(inst, "lib"); got != "2.0 [1.0]" {
+		t.Errorf("refused uninstall left %s, want 2.0 [1.0]", got)
+	}
+
+	if err := inst.Uninstall("lib", true); err != nil {
+		t.Fatal(err)
+	}
+	if inst.State.IsInstalled("lib") {
+		t.Error("lib still installed after a forced uninstall")
+	}
+	if exists(inst.Paths.AppDir("lib", "1.0")) {
+		t.Error("inactive v1.0 of lib left on disk")
+	}
+}
+
+func TestRollback(t *testing.T) {
+	inst := newTestInstaller(t)
+	reg := newTestRegistry(t, inst)
+	reg.add("tool", "1.0", defaultSteps)
+	reg.add("tool", "2.0", defaultSteps)
+
+	if err := inst.Rollback("tool"); err == nil || !strings.Contains(err.Error(), "not installed") {
+		t.Errorf("rolling back a missing package: got %v", err)
+	}
+
+	if err := inst.Install(reg.get("tool", "1.0")); err != nil {
+		t.Fatal(err)
+	}
+	if err := inst.Rollback("tool"); err == nil || !strings.Contains(err.Error(), "no previous version") {
+		t.Errorf("rolling back a single version: got %v", err)
+	}
+
+	if err := inst.Upgrade(reg.get("tool", "2.0")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rolling back needs neither the registry nor the download cache
+	for _, dir := range []string{reg.dir, inst.Paths.Cache} {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, want := range []string{"1.0", "2.0"} {
+		if err := inst.Rollback("tool"); err != nil {
+			t.Fatal(err)
+		}
+		if !shimFor(inst, "tool", want) {
+			t.Errorf("shim does not run v%s after rolling back", want)
+		}
+	}
+	if got := installedVersions(inst, "tool"); got != "2.0 [1.0]" {
+		t.Errorf("installed %s after rolling back twice, want 2.0 [1.0]", got)
+	}
+}
+
+func TestRollbackChecksDependencies(t *testing.T) {
+	tests := []struct {
+		name    string
+		depends string
+		lib     string // installed version of lib, if any
+		want    string
+	}{
+		{name: "satisfied", depends: "lib >=1", lib: "1.0"},
+		{name: "missing", depends: "lib", want: "Warning: app v1.0 requires lib, which is not installed"},
+		{name: "too old", depends: "lib >=2", lib: "1.0", want: "Warning: app v1.0 requires lib >=2, but v1.0 is installed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inst := newTestInstaller(t)
+			reg := newTestRegistry(t, inst)
+			reg.add("app", "1.0", defaultSteps, tt.depends)
+			reg.add("app", "2.0", defaultSteps)
+
+			// Install app 1.0 as if its dependency were met, then upgrade to
+			// 2.0, which needs none
+			if err := inst.Install(reg.get("app", "1.0")); err != nil {
+				t.Fatal(err)
+			}
+			if err := inst.Upgrade(reg.get("app", "2.0")); err != nil {
+				t.Fatal(err)
+			}
+			if tt.lib != "" {
+				reg.add("lib", tt.lib, defaultSteps)
+				if err := inst.Install(reg.get("lib", tt.lib)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var err error
+			out := captureOutput(t, func() { err = inst.Rollback("app") })
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			warned := strings.Contains(out, "Warning:")
+			if tt.want == "" && warned {
+				t.Errorf("unexpected warning in:\n%s", out)
+			}
+			if tt.want != "" && !strings.Contains(out, tt.want) {
+				t.Errorf("missing %q in:\n%s", tt.want, out)
+			}
+			if got := installedVersions(inst, "app"); got != "1.0 [2.0]" {
+				t.Errorf("installed %s, want 1.0 [2.0]", got)
+			}
+		})
+	}
+}
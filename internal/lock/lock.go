@@ -0,0 +1,66 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gofrs/flock"
+)
+
+// retryDelay is how often a busy lock is retried
+const retryDelay = 100 * time.Millisecond
+
+// ErrBusy is returned when the lock is still held by another process after
+// the timeout
+var ErrBusy = errors.New("another gbpm is running")
+
+// Lock is an exclusive lock on a lock file, shared by every gbpm process.
+// The operating system releases it if the process dies.
+type Lock struct {
+	f *flock.Flock
+}
+
+// Acquire takes the exclusive lock on path, waiting up to timeout for
+// another process to release it. waiting is called once before waiting.
+func Acquire(path string, timeout time.Duration, waiting func()) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	f := flock.New(path)
+	locked, err := f.TryLock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	if !locked {
+		if timeout <= 0 {
+			return nil, ErrBusy
+		}
+		if waiting != nil {
+			waiting()
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		locked, err = f.TryLockContext(ctx, retryDelay)
+		if !locked {
+			if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+				return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+			}
+			return nil, ErrBusy
+		}
+	}
+
+	return &Lock{f: f}, nil
+}
+
+// Release releases the lock
+func (l *Lock) Release() error {
+	return l.f.Unlock()
+}
@@ -4,19 +4,62 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strings"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/util"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/version"
 )
 
 // Manifest represents a package manifest
 type Manifest struct {
-	Name        string     `yaml:"name"`
-	Version     string     `yaml:"version"`
-	Description string     `yaml:"description,omitempty"`
-	Homepage    string     `yaml:"homepage,omitempty"`
-	License     string     `yaml:"license,omitempty"`
-	Platforms   []Platform `yaml:"platforms"`
-	Install     Install    `yaml:"install"`
+	Name        string       `yaml:"name"`
+	Version     string       `yaml:"version"`
+	Description string       `yaml:"description,omitempty"`
+	Homepage    string       `yaml:"homepage,omitempty"`
+	License     string       `yaml:"license,omitempty"`
+	Tags        []string     `yaml:"tags,omitempty"`
+	Depends     []Dependency `yaml:"depends,omitempty"`
+	Platforms   []Platform   `yaml:"platforms"`
+	Install     Install      `yaml:"install"`
+
+	// Registry is the registry the manifest was loaded from, empty for local files
+	Registry string `yaml:"-"`
+}
+
+// Dependency represents another package this package needs.
+//
+// In YAML it is either a string such as "jq" or "jq >=1.6", or a mapping
+// with name and version keys.
+type Dependency struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version,omitempty"`
+}
+
+// UnmarshalYAML accepts both the string and the mapping form of a dependency
+func (d *Dependency) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		value := strings.TrimSpace(node.Value)
+		d.Name = value
+		d.Version = ""
+		if idx := strings.IndexAny(value, " <>=!~^"); idx >= 0 {
+			d.Name = value[:idx]
+			d.Version = strings.TrimSpace(value[idx:])
+		}
+		return nil
+	}
+
+	type plain Dependency
+	return node.Decode((*plain)(d))
+}
+
+// String returns the dependency in its string form
+func (d Dependency) String() string {
+	if d.Version == "" {
+		return d.Name
+	}
+	return d.Name + " " + d.Version
 }
 
 // Platform represents a platform-specific artifact
@@ -38,6 +81,14 @@ type InstallStep struct {
 	Type string `yaml:"type"`
 	From string `yaml:"from,omitempty"`
 	To   string `yaml:"to,omitempty"`
+
+	// StripComponents, Include and Exclude apply to extract steps
+	StripComponents int      `yaml:"strip_components,omitempty"`
+	Include         []string `yaml:"include,omitempty"`
+	Exclude         []string `yaml:"exclude,omitempty"`
+
+	// Args are passed before the caller's arguments by shim steps
+	Args []string `yaml:"args,omitempty"`
 }
 
 // LoadManifest loads and parses a manifest file
@@ -47,6 +98,11 @@ func LoadManifest(path string) (*Manifest, error) {
 		return nil, fmt.Errorf("failed to read manifest: %w", err)
 	}
 
+	return Parse(data)
+}
+
+// Parse parses and validates manifest contents
+func Parse(data []byte) (*Manifest, error) {
 	var m Manifest
 	if err := yaml.Unmarshal(data, &m); err != nil {
 		return nil, fmt.Errorf("failed to parse manifest: %w", err)
@@ -73,6 +129,48 @@ func (m *Manifest) Validate() error {
 	if len(m.Install.Steps) == 0 {
 		return fmt.Errorf("at least one install step is required")
 	}
+	for _, d := range m.Depends {
+		if d.Name == "" {
+			return fmt.Errorf("dependency name is required")
+		}
+		if strings.Contains(d.Name, "/") {
+			return fmt.Errorf("dependency %s: registry-qualified names are not allowed", d.Name)
+		}
+		if d.Name == m.Name {
+			return fmt.Errorf("package cannot depend on itself")
+		}
+		if d.Version != "" {
+			if _, err := version.ParseConstraint(d.Version); err != nil {
+				return fmt.Errorf("dependency %s: %w", d.Name, err)
+			}
+		}
+	}
+	for idx, step := range m.Install.Steps {
+		if step.Type == "shim" {
+			if step.From == "" {
+				return fmt.Errorf("step %d: shim requires from", idx+1)
+			}
+			if strings.ContainsAny(step.To, `/\`) {
+				return fmt.Errorf("step %d: shim name %q must not contain a path", idx+1, step.To)
+			}
+		}
+		if step.StripComponents < 0 {
+			return fmt.Errorf("step %d: strip_components must not be negative", idx+1)
+		}
+		for _, glob := range append(append([]string{}, step.Include...), step.Exclude...) {
+			if err := util.ValidateGlob(glob); err != nil {
+				return fmt.Errorf("step %d: %w", idx+1, err)
+			}
+		}
+	}
+	for _, p := range m.Platforms {
+		if p.Checksum == "" {
+			continue
+		}
+		if _, _, err := util.ParseChecksum(p.Checksum); err != nil {
+			return fmt.Errorf("platform %s/%s: %w", p.OS, p.Arch, err)
+		}
+	}
 	return nil
 }
 
@@ -10,6 +10,7 @@ type Paths struct {
 	Bin      string
 	Cache    string
 	Registry string
+	Apps     string
 }
 
 func NewDefault() *Paths {
@@ -22,9 +23,16 @@ func NewDefault() *Paths {
 		Bin:      filepath.Join(gbpmHome, "bin"),
 		Cache:    filepath.Join(gbpmHome, "cache"),
 		Registry: filepath.Join(gbpmHome, "registry"),
+		Apps:     filepath.Join(gbpmHome, "apps"),
 	}
 }
 
+// AppDir returns the directory a package version is installed into when it
+// keeps its files together instead of copying them into Bin
+func (p *Paths) AppDir(name, version string) string {
+	return filepath.Join(p.Apps, name, version)
+}
+
 func getEnvOrDefault(key, def string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
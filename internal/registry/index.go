@@ -0,0 +1,186 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/manifest"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/util"
+)
+
+const indexFile = ".gbpm-index.json"
+
+// Index is a prebuilt summary of every manifest in the registry
+type Index struct {
+	GeneratedAt time.Time    `json:"generated_at"`
+	Packages    []IndexEntry `json:"packages"`
+}
+
+// IndexEntry summarises a single package manifest
+type IndexEntry struct {
+	Registry    string   `json:"registry"`
+	Name        string   `json:"name"`
+	Version     string   `json:"version"`
+	Description string   `json:"description,omitempty"`
+	Homepage    string   `json:"homepage,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// IndexPath returns the path of the registry index file
+func (r *Registry) IndexPath() string {
+	if r.indexPath != "" {
+		return r.indexPath
+	}
+	return filepath.Join(r.Path, indexFile)
+}
+
+// BuildIndex scans every manifest in the registry and writes the index file
+func (r *Registry) BuildIndex() (*Index, error) {
+	if _, err := os.Stat(r.Path); os.IsNotExist(err) {
+		return nil, fmt.Errorf("registry %s not found, run 'gbpm update' first", r.Name)
+	}
+
+	dirs, err := os.ReadDir(filepath.Join(r.Path, "packages"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry packages: %w", err)
+	}
+
+	idx := &Index{GeneratedAt: time.Now()}
+	skipped := 0
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+
+		name := dir.Name()
+		manifestPath := filepath.Join(r.Path, "packages", name, name+".yaml")
+		if _, err := os.Stat(manifestPath); err != nil {
+			continue
+		}
+
+		m, err := manifest.LoadManifest(manifestPath)
+		if err != nil {
+			skipped++
+			continue
+		}
+
+		idx.Packages = append(idx.Packages, IndexEntry{
+			Registry:    r.Name,
+			Name:        m.Name,
+			Version:     m.Version,
+			Description: m.Description,
+			Homepage:    m.Homepage,
+			Tags:        m.Tags,
+		})
+	}
+
+	if skipped > 0 {
+		fmt.Printf("Warning: skipped %d invalid manifest(s) while indexing\n", skipped)
+	}
+
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal index: %w", err)
+	}
+
+	// Directory registries keep their index in GBPM_REGISTRY, which may not
+	// exist yet
+	if err := os.MkdirAll(filepath.Dir(r.IndexPath()), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create index directory: %w", err)
+	}
+
+	// Commands that only read the registry rebuild a missing index without
+	// the home lock, so never let them see a partly written one
+	if err := util.WriteFileAtomic(r.IndexPath(), data); err != nil {
+		return nil, fmt.Errorf("failed to write index: %w", err)
+	}
+
+	return idx, nil
+}
+
+// LoadIndex reads the registry index, building it first if it is missing
+func (r *Registry) LoadIndex() (*Index, error) {
+	data, err := os.ReadFile(r.IndexPath())
+	if os.IsNotExist(err) {
+		return r.BuildIndex()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	var idx Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		// A corrupt index is only a cache, so rebuild it
+		return r.BuildIndex()
+	}
+
+	return &idx, nil
+}
+
+// Search returns the packages whose name, description, homepage or tags
+// contain term, case-insensitively. Name matches are ranked first: exact,
+// then prefix, then substring. Equally ranked packages are sorted by name,
+// keeping the order of idx for equal names. An empty term matches every
+// package.
+func (idx *Index) Search(term string) []IndexEntry {
+	term = strings.ToLower(strings.TrimSpace(term))
+
+	type match struct {
+		entry IndexEntry
+		rank  int
+	}
+
+	var matches []match
+	for _, e := range idx.Packages {
+		rank := matchRank(e, term)
+		if rank < 0 {
+			continue
+		}
+		matches = append(matches, match{entry: e, rank: rank})
+	}
+
+	sort.SliceStable(matches, func(a, b int) bool {
+		if matches[a].rank != matches[b].rank {
+			return matches[a].rank < matches[b].rank
+		}
+		return matches[a].entry.Name < matches[b].entry.Name
+	})
+
+	results := make([]IndexEntry, len(matches))
+	for i, m := range matches {
+		results[i] = m.entry
+	}
+	return results
+}
+
+// matchRank returns how well an entry matches term, lower is better, or -1
+// if it does not match at all
+func matchRank(e IndexEntry, term string) int {
+	name := strings.ToLower(e.Name)
+	switch {
+	case name == term:
+		return 0
+	case strings.HasPrefix(name, term):
+		return 1
+	case strings.Contains(name, term):
+		return 2
+	}
+
+	for _, tag := range e.Tags {
+		if strings.Contains(strings.ToLower(tag), term) {
+			return 3
+		}
+	}
+
+	if strings.Contains(strings.ToLower(e.Description), term) ||
+		strings.Contains(strings.ToLower(e.Homepage), term) {
+		return 4
+	}
+
+	return -1
+}
@@ -0,0 +1,56 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetSearch(t *testing.T) {
+	s := &Set{Root: t.TempDir()}
+	for _, r := range []struct {
+		name     string
+		priority int
+		packages []string
+	}{
+		{name: "low", priority: 0, packages: []string{"tool", "toolbox", "atool"}},
+		{name: "high", priority: 10, packages: []string{"tool", "mytool", "hammer"}},
+	} {
+		dir := t.TempDir()
+		for _, pkg := range r.packages {
+			writeManifests(t, dir, pkg, map[string]string{pkg + ".yaml": "1.0"})
+		}
+		reg, err := s.Add(r.name, dir, r.priority)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := reg.BuildIndex(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		term string
+		want string
+	}{
+		// Exact, then prefix, then substring matches, each sorted by name
+		// with the higher priority registry first for the same name
+		{term: "tool", want: "high/tool low/tool low/toolbox low/atool high/mytool"},
+		{term: "TOOLBOX", want: "low/toolbox"},
+		{term: "", want: "low/atool high/hammer high/mytool high/tool low/tool low/toolbox"},
+		{term: "missing", want: ""},
+	}
+
+	for _, tt := range tests {
+		results, err := s.Search(tt.term)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range results {
+			got = append(got, e.Registry+"/"+e.Name)
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("Search(%q) = %s, want %s", tt.term, strings.Join(got, " "), tt.want)
+		}
+	}
+}
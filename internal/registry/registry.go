@@ -5,22 +5,24 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/util"
 )
 
 const defaultRegistry = "https://github.com/Foggy-Forge/git-bash-package-manager-registry.git"
 
-// Registry manages the package registry
+// Registry manages a single package registry ("bucket")
 type Registry struct {
-	Path string
-	URL  string
-}
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Priority int    `json:"priority"`
+	Path     string `json:"-"`
+
+	// configURL is the URL from the config file, before any environment override
+	configURL string
 
-// New creates a new registry manager
-func New(path string) (*Registry, error) {
-	return &Registry{
-		Path: path,
-		URL:  getRegistryURL(),
-	}, nil
+	// indexPath overrides where the search index is stored
+	indexPath string
 }
 
 // Clone clones the registry repository
@@ -30,7 +32,7 @@ func (r *Registry) Clone() error {
 		return fmt.Errorf("registry already exists, use 'gbpm update' to update")
 	}
 
-	fmt.Printf("Cloning registry from %s...\n", r.URL)
+	fmt.Printf("Cloning registry %s from %s...\n", r.Name, util.RedactURL(r.URL))
 
 	// Create parent directory
 	if err := os.MkdirAll(filepath.Dir(r.Path), 0755); err != nil {
@@ -46,18 +48,30 @@ func (r *Registry) Clone() error {
 		return fmt.Errorf("failed to clone registry: %w", err)
 	}
 
-	fmt.Println("✓ Registry cloned successfully")
+	fmt.Printf("✓ Registry %s cloned successfully\n", r.Name)
 	return nil
 }
 
-// Pull updates the registry repository
+// Pull updates the registry from its source
 func (r *Registry) Pull() error {
+	switch r.Type() {
+	case TypeDir:
+		return r.checkDir()
+	case TypeTarball:
+		return r.pullTarball()
+	default:
+		return r.pullGit()
+	}
+}
+
+// pullGit updates the registry repository, cloning it first if needed
+func (r *Registry) pullGit() error {
 	// Check if registry exists
 	if _, err := os.Stat(filepath.Join(r.Path, ".git")); os.IsNotExist(err) {
 		return r.Clone()
 	}
 
-	fmt.Println("Updating registry...")
+	fmt.Printf("Updating registry %s...\n", r.Name)
 
 	cmd := exec.Command("git", "-C", r.Path, "pull")
 	cmd.Stdout = os.Stdout
@@ -67,7 +81,7 @@ func (r *Registry) Pull() error {
 		return fmt.Errorf("failed to update registry: %w", err)
 	}
 
-	fmt.Println("✓ Registry updated successfully")
+	fmt.Printf("✓ Registry %s updated successfully\n", r.Name)
 	return nil
 }
 
@@ -75,22 +89,14 @@ func (r *Registry) Pull() error {
 func (r *Registry) FindManifest(name string) (string, error) {
 	// Check if registry exists
 	if _, err := os.Stat(r.Path); os.IsNotExist(err) {
-		return "", fmt.Errorf("registry not found, run 'gbpm update' first")
+		return "", fmt.Errorf("registry %s not found, run 'gbpm update' first", r.Name)
 	}
 
 	// Look for manifest
 	manifestPath := filepath.Join(r.Path, "packages", name, name+".yaml")
 	if _, err := os.Stat(manifestPath); os.IsNotExist(err) {
-		return "", fmt.Errorf("package '%s' not found in registry", name)
+		return "", fmt.Errorf("package '%s' not found in registry %s", name, r.Name)
 	}
 
 	return manifestPath, nil
 }
-
-// getRegistryURL gets the registry URL from environment or uses default
-func getRegistryURL() string {
-	if url := os.Getenv("GBPM_REGISTRY_URL"); url != "" {
-		return url
-	}
-	return defaultRegistry
-}
@@ -0,0 +1,352 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/manifest"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/util"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/version"
+)
+
+const (
+	// DefaultName is the name of the registry configured out of the box
+	DefaultName = "main"
+
+	configFile = "registries.json"
+)
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// Set is the configured collection of registries, ordered by priority.
+// Each registry is cloned into its own directory under Root.
+type Set struct {
+	Root       string
+	Registries []*Registry
+}
+
+type setConfig struct {
+	Registries []*Registry `json:"registries"`
+}
+
+// Load loads the registry configuration from root, creating the default
+// configuration if none exists yet. A registry in the layout of older
+// versions of gbpm must be moved with MigrateLegacyLayout first.
+func Load(root string) (*Set, error) {
+	if NeedsMigration(root) {
+		return nil, fmt.Errorf("registry %s uses the layout of an older gbpm and must be migrated first", root)
+	}
+
+	s := &Set{Root: root}
+
+	data, err := os.ReadFile(filepath.Join(root, configFile))
+	if os.IsNotExist(err) {
+		s.Registries = []*Registry{{
+			Name: DefaultName,
+			URL:  defaultRegistry,
+		}}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read registry config: %w", err)
+	} else {
+		var cfg setConfig
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse registry config: %w", err)
+		}
+		s.Registries = cfg.Registries
+	}
+
+	for _, r := range s.Registries {
+		r.configURL = r.URL
+		if r.Name == DefaultName {
+			if url := os.Getenv("GBPM_REGISTRY_URL"); url != "" {
+				r.URL = url
+			}
+		}
+		s.attach(r)
+	}
+	s.sort()
+
+	return s, nil
+}
+
+// Save writes the registry configuration
+func (s *Set) Save() error {
+	if err := os.MkdirAll(s.Root, 0755); err != nil {
+		return fmt.Errorf("failed to create registry directory: %w", err)
+	}
+
+	// Don't persist a URL overridden from the environment
+	cfg := setConfig{}
+	for _, r := range s.Registries {
+		saved := *r
+		if r.configURL != "" {
+			saved.URL = r.configURL
+		}
+		cfg.Registries = append(cfg.Registries, &saved)
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal registry config: %w", err)
+	}
+
+	if err := util.WriteFileAtomic(filepath.Join(s.Root, configFile), data); err != nil {
+		return fmt.Errorf("failed to write registry config: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the registry with the given name
+func (s *Set) Get(name string) (*Registry, bool) {
+	for _, r := range s.Registries {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// Add adds a registry. It is not cloned until the next Pull.
+func (s *Set) Add(name, url string, priority int) (*Registry, error) {
+	if !namePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid registry name %q: use lowercase letters, digits, '.', '_' and '-'", name)
+	}
+	if _, exists := s.Get(name); exists {
+		return nil, fmt.Errorf("registry %s already exists", name)
+	}
+
+	// Relative local paths would change meaning with the working directory
+	if path, ok := localPath(url); ok && !strings.HasPrefix(url, "file://") {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid registry path %q: %w", url, err)
+		}
+		url = abs
+	}
+
+	r := &Registry{
+		Name:     name,
+		URL:      url,
+		Priority: priority,
+	}
+	s.attach(r)
+	s.Registries = append(s.Registries, r)
+	s.sort()
+
+	return r, nil
+}
+
+// Remove removes a registry and deletes its clone
+func (s *Set) Remove(name string) error {
+	for idx, r := range s.Registries {
+		if r.Name != name {
+			continue
+		}
+
+		// Directory registries belong to the user, only drop our index
+		target := r.Path
+		if r.Type() == TypeDir {
+			target = r.IndexPath()
+		}
+		if err := os.RemoveAll(target); err != nil {
+			return fmt.Errorf("failed to remove registry %s: %w", name, err)
+		}
+
+		s.Registries = append(s.Registries[:idx], s.Registries[idx+1:]...)
+		return nil
+	}
+
+	return fmt.Errorf("registry %s not found", name)
+}
+
+// SetPriority changes the resolution priority of a registry
+func (s *Set) SetPriority(name string, priority int) error {
+	r, ok := s.Get(name)
+	if !ok {
+		return fmt.Errorf("registry %s not found", name)
+	}
+
+	r.Priority = priority
+	s.sort()
+	return nil
+}
+
+// Pull clones or updates every registry and rebuilds its search index.
+// Every registry is attempted even if an earlier one fails.
+func (s *Set) Pull() error {
+	var failed []string
+	for _, r := range s.Registries {
+		if err := r.Pull(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			failed = append(failed, r.Name)
+			continue
+		}
+
+		idx, err := r.BuildIndex()
+		if err != nil {
+			fmt.Printf("Error: failed to build index for registry %s: %v\n", r.Name, err)
+			failed = append(failed, r.Name)
+			continue
+		}
+		fmt.Printf("✓ Indexed %d packages in %s\n", len(idx.Packages), r.Name)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to update registries: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// FindManifest finds a manifest by package name. A qualified name such as
+// "bucket/pkg" only looks in that registry; an unqualified name returns the
+// match from the registry with the highest priority.
+func (s *Set) FindManifest(name string) (*Registry, string, error) {
+	if bucket, pkg, ok := strings.Cut(name, "/"); ok {
+		r, found := s.Get(bucket)
+		if !found {
+			return nil, "", fmt.Errorf("registry %s not found", bucket)
+		}
+		path, err := r.FindManifest(pkg)
+		if err != nil {
+			return nil, "", err
+		}
+		return r, path, nil
+	}
+
+	available := false
+	for _, r := range s.Registries {
+		if _, err := os.Stat(r.Path); err != nil {
+			continue
+		}
+		available = true
+
+		if path, err := r.FindManifest(name); err == nil {
+			return r, path, nil
+		}
+	}
+
+	if !available {
+		return nil, "", fmt.Errorf("registry not found, run 'gbpm update' first")
+	}
+	return nil, "", fmt.Errorf("package '%s' not found in any registry", name)
+}
+
+// FindManifestVersion finds the manifest of the highest version of a package
+// that satisfies constraint. Names are resolved as by FindManifest; an
+// unqualified name returns the match from the registry with the highest
+// priority that has a matching version.
+func (s *Set) FindManifestVersion(name string, constraint *version.Constraint) (*Registry, *manifest.Manifest, error) {
+	if bucket, pkg, ok := strings.Cut(name, "/"); ok {
+		r, found := s.Get(bucket)
+		if !found {
+			return nil, nil, fmt.Errorf("registry %s not found", bucket)
+		}
+		m, err := r.FindManifestVersion(pkg, constraint)
+		if err != nil {
+			return nil, nil, err
+		}
+		return r, m, nil
+	}
+
+	var errs []error
+	for _, r := range s.Registries {
+		if _, err := r.FindManifest(name); err != nil {
+			continue
+		}
+
+		m, err := r.FindManifestVersion(name, constraint)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return r, m, nil
+	}
+
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+
+	// Report a missing registry or package as FindManifest does
+	_, _, err := s.FindManifest(name)
+	return nil, nil, err
+}
+
+// Search searches the index of every cloned registry. Results are ranked
+// by how well they match and then by name; a package found in several
+// registries is listed once for each, in order of registry priority.
+func (s *Set) Search(term string) ([]IndexEntry, error) {
+	combined := &Index{}
+	for _, r := range s.Registries {
+		if _, err := os.Stat(r.Path); err != nil {
+			continue
+		}
+
+		idx, err := r.LoadIndex()
+		if err != nil {
+			return nil, err
+		}
+		combined.Packages = append(combined.Packages, idx.Packages...)
+	}
+
+	return combined.Search(term), nil
+}
+
+// attach sets where a registry lives on disk. Directory registries are used
+// in place, so their index is kept under Root instead.
+func (s *Set) attach(r *Registry) {
+	r.Path = filepath.Join(s.Root, r.Name)
+	r.indexPath = ""
+
+	if r.Type() == TypeDir {
+		path, _ := localPath(r.URL)
+		r.Path = path
+		r.indexPath = filepath.Join(s.Root, "."+r.Name+indexFile)
+	}
+}
+
+// sort orders registries by descending priority, keeping insertion order
+// for equal priorities
+func (s *Set) sort() {
+	sort.SliceStable(s.Registries, func(a, b int) bool {
+		return s.Registries[a].Priority > s.Registries[b].Priority
+	})
+}
+
+// NeedsMigration reports whether root holds a registry cloned directly into
+// it by an older version of gbpm
+func NeedsMigration(root string) bool {
+	_, err := os.Stat(filepath.Join(root, ".git"))
+	return err == nil
+}
+
+// MigrateLegacyLayout moves a registry cloned directly into root by older
+// versions of gbpm into root/main. It moves the clone out from under any
+// other gbpm process, so the caller must hold the home lock. It does nothing
+// if root needs no migration.
+func MigrateLegacyLayout(root string) error {
+	if !NeedsMigration(root) {
+		return nil
+	}
+
+	fmt.Printf("Moving registry clone to %s...\n", filepath.Join(root, DefaultName))
+
+	tmp := root + ".migrate"
+	if err := os.Rename(root, tmp); err != nil {
+		return fmt.Errorf("failed to migrate registry: %w", err)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return fmt.Errorf("failed to migrate registry: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(root, DefaultName)); err != nil {
+		return fmt.Errorf("failed to migrate registry: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package registry
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/util"
+)
+
+// Registry source types, selected from the registry URL
+const (
+	// TypeGit is a git repository cloned into GBPM_REGISTRY/<name>
+	TypeGit = "git"
+	// TypeDir is a local directory used in place
+	TypeDir = "dir"
+	// TypeTarball is a .tar.gz snapshot downloaded and unpacked into GBPM_REGISTRY/<name>
+	TypeTarball = "tarball"
+)
+
+// Type returns the source type of the registry:
+//
+//   - URLs ending in .tar.gz or .tgz (http, https, file or a plain path) are tarballs
+//   - file:// URLs and plain local paths are directories
+//   - anything else is a git repository
+func (r *Registry) Type() string {
+	lower := strings.ToLower(r.URL)
+	if strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz") {
+		return TypeTarball
+	}
+	if _, ok := localPath(r.URL); ok {
+		return TypeDir
+	}
+	return TypeGit
+}
+
+// localPath returns the filesystem path for file:// URLs and plain paths
+func localPath(rawURL string) (string, bool) {
+	if strings.HasPrefix(rawURL, "file://") {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return "", false
+		}
+		path := u.Path
+		// file:///C:/registry parses to /C:/registry
+		if runtime.GOOS == "windows" && len(path) > 2 && path[0] == '/' && path[2] == ':' {
+			path = path[1:]
+		}
+		return filepath.FromSlash(path), true
+	}
+
+	if filepath.IsAbs(rawURL) || strings.HasPrefix(rawURL, ".") {
+		return rawURL, true
+	}
+
+	return "", false
+}
+
+// checkDir verifies that a directory registry exists and looks like a registry
+func (r *Registry) checkDir() error {
+	if _, err := os.Stat(filepath.Join(r.Path, "packages")); err != nil {
+		return fmt.Errorf("registry %s: %s is not a registry directory (no packages/ found)", r.Name, r.Path)
+	}
+
+	fmt.Printf("✓ Registry %s uses local directory %s\n", r.Name, r.Path)
+	return nil
+}
+
+// pullTarball downloads and unpacks a tarball snapshot, replacing the
+// previous snapshot only once the new one has been unpacked
+func (r *Registry) pullTarball() error {
+	fmt.Printf("Fetching registry %s from %s...\n", r.Name, util.RedactURL(r.URL))
+
+	if err := os.MkdirAll(filepath.Dir(r.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmpDir, err := os.MkdirTemp(filepath.Dir(r.Path), "."+r.Name+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	archive, ok := localPath(r.URL)
+	if !ok {
+		archive = filepath.Join(tmpDir, "registry.tar.gz")
+		if err := util.Download(r.URL, archive); err != nil {
+			return fmt.Errorf("failed to download registry %s: %w", r.Name, err)
+		}
+	}
+
+	unpacked := filepath.Join(tmpDir, "registry")
+	if err := util.ExtractTarGz(archive, unpacked); err != nil {
+		return fmt.Errorf("failed to unpack registry %s: %w", r.Name, err)
+	}
+
+	root, err := snapshotRoot(unpacked)
+	if err != nil {
+		return fmt.Errorf("registry %s: %w", r.Name, err)
+	}
+
+	// Keep the previous snapshot until the new one is in place
+	old := filepath.Join(tmpDir, "old")
+	if _, err := os.Stat(r.Path); err == nil {
+		if err := os.Rename(r.Path, old); err != nil {
+			return fmt.Errorf("failed to replace registry %s: %w", r.Name, err)
+		}
+	}
+	if err := os.Rename(root, r.Path); err != nil {
+		_ = os.Rename(old, r.Path)
+		return fmt.Errorf("failed to replace registry %s: %w", r.Name, err)
+	}
+
+	fmt.Printf("✓ Registry %s updated successfully\n", r.Name)
+	return nil
+}
+
+// snapshotRoot finds the directory containing packages/ in an unpacked
+// snapshot, allowing for a single top-level directory such as the one in
+// GitHub source archives
+func snapshotRoot(dir string) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, "packages")); err == nil {
+		return dir, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		sub := filepath.Join(dir, entries[0].Name())
+		if _, err := os.Stat(filepath.Join(sub, "packages")); err == nil {
+			return sub, nil
+		}
+	}
+
+	return "", fmt.Errorf("snapshot does not contain a packages/ directory")
+}
@@ -0,0 +1,138 @@
+package registry
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/manifest"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/version"
+)
+
+// versionsDir holds the manifests of older versions of a package, as
+// packages/<name>/versions/<version>.yaml
+const versionsDir = "versions"
+
+// FindManifestVersion returns the manifest of the highest version of a
+// package that satisfies constraint. The latest manifest and those under
+// packages/<name>/versions/ are searched first; if none matches and the
+// registry is a git clone, older versions are recovered from the history of
+// the latest manifest.
+func (r *Registry) FindManifestVersion(name string, constraint *version.Constraint) (*manifest.Manifest, error) {
+	latestPath, err := r.FindManifest(name)
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := manifest.LoadManifest(latestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	available := append([]string{latest.Version}, r.storedVersions(name)...)
+
+	best := ""
+	for _, v := range available {
+		if constraint.CheckString(v) && (best == "" || version.Compare(v, best) > 0) {
+			best = v
+		}
+	}
+
+	switch {
+	case best == latest.Version:
+		return latest, nil
+	case best != "":
+		return r.loadStoredVersion(name, best)
+	}
+
+	m, err := r.historyVersion(name, constraint)
+	if err != nil {
+		return nil, err
+	}
+	if m != nil {
+		return m, nil
+	}
+
+	sort.Slice(available, func(a, b int) bool {
+		return version.Compare(available[a], available[b]) > 0
+	})
+	return nil, fmt.Errorf("no version of '%s' matching %s in registry %s (available: %s)",
+		name, constraint, r.Name, strings.Join(available, ", "))
+}
+
+// storedVersions lists the versions in packages/<name>/versions/
+func (r *Registry) storedVersions(name string) []string {
+	entries, err := os.ReadDir(filepath.Join(r.Path, "packages", name, versionsDir))
+	if err != nil {
+		return nil
+	}
+
+	var versions []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".yaml") {
+			continue
+		}
+		versions = append(versions, strings.TrimSuffix(entry.Name(), ".yaml"))
+	}
+	return versions
+}
+
+// loadStoredVersion loads packages/<name>/versions/<ver>.yaml, checking that
+// it describes the version its file name says
+func (r *Registry) loadStoredVersion(name, ver string) (*manifest.Manifest, error) {
+	m, err := manifest.LoadManifest(filepath.Join(r.Path, "packages", name, versionsDir, ver+".yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Name != name || version.Compare(m.Version, ver) != 0 {
+		return nil, fmt.Errorf("registry %s: %s/%s/%s.yaml describes %s v%s",
+			r.Name, name, versionsDir, ver, m.Name, m.Version)
+	}
+	return m, nil
+}
+
+// historyVersion searches the git history of the latest manifest of a
+// package for the highest version that satisfies constraint. It returns nil
+// if the registry is not a git clone or no version matches.
+func (r *Registry) historyVersion(name string, constraint *version.Constraint) (*manifest.Manifest, error) {
+	if _, err := os.Stat(filepath.Join(r.Path, ".git")); err != nil {
+		return nil, nil
+	}
+
+	rel := path.Join("packages", name, name+".yaml")
+	out, err := exec.Command("git", "-C", r.Path, "log", "--format=%H", "--", rel).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history of registry %s: %w", r.Name, err)
+	}
+
+	var best *manifest.Manifest
+	seen := make(map[string]bool)
+	for _, commit := range strings.Fields(string(out)) {
+		data, err := exec.Command("git", "-C", r.Path, "show", commit+":"+rel).Output()
+		if err != nil {
+			// Deleted in this commit
+			continue
+		}
+
+		// Old manifests that no longer validate are skipped
+		m, err := manifest.Parse(data)
+		if err != nil || m.Name != name || seen[m.Version] {
+			continue
+		}
+		seen[m.Version] = true
+
+		if constraint.CheckString(m.Version) && (best == nil || version.Compare(m.Version, best.Version) > 0) {
+			best = m
+		}
+	}
+
+	if best != nil {
+		fmt.Printf("Found %s v%s in the history of registry %s\n", name, best.Version, r.Name)
+	}
+	return best, nil
+}
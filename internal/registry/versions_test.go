@@ -0,0 +1,156 @@
+package registry
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/version"
+)
+
+// testManifest returns a minimal valid manifest of a package version
+func testManifest(name, ver string) string {
+	return fmt.Sprintf(`name: %s
+version: %q
+platforms:
+  - os: windows
+    arch: amd64
+    url: https://example.invalid/%s-%s.exe
+install:
+  steps:
+    - shim: {from: "{{ .AppDir }}/%s.exe"}
+`, name, ver, name, ver, name)
+}
+
+// writeManifests writes files relative to the packages/<name> directory of
+// a registry, each holding the manifest of the version it maps to
+func writeManifests(t *testing.T, root, name string, files map[string]string) {
+	t.Helper()
+	for file, ver := range files {
+		path := filepath.Join(root, "packages", name, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(testManifest(name, ver)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func findVersion(r *Registry, name, spec string) (string, error) {
+	c, err := version.ParseConstraint(spec)
+	if err != nil {
+		return "", err
+	}
+	m, err := r.FindManifestVersion(name, c)
+	if err != nil {
+		return "", err
+	}
+	return m.Version, nil
+}
+
+func TestFindManifestVersion(t *testing.T) {
+	r := &Registry{Name: "test", Path: t.TempDir()}
+	writeManifests(t, r.Path, "tool", map[string]string{
+		"tool.yaml":          "3.0",
+		"versions/1.0.yaml":  "1.0",
+		"versions/2.0.yaml":  "2.0",
+		"versions/2.1.yaml":  "2.1",
+		"versions/notes.txt": "9.0",
+	})
+
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr string
+	}{
+		{spec: ">=1", want: "3.0"},
+		{spec: "3", want: "3.0"},
+		{spec: "^2", want: "2.1"},
+		{spec: "~2.0", want: "2.0"},
+		{spec: "1.0.0", want: "1.0"},
+		{spec: "<2", want: "1.0"},
+		{spec: "9", wantErr: "no version of 'tool' matching 9 in registry test (available: 3.0, 2.1, 2.0, 1.0)"},
+	}
+
+	for _, tt := range tests {
+		got, err := findVersion(r, "tool", tt.spec)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: got %s, %v, want error %q", tt.spec, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %s, %v, want %s", tt.spec, got, err, tt.want)
+		}
+	}
+
+	if _, err := findVersion(r, "missing", "1"); err == nil || !strings.Contains(err.Error(), "package 'missing' not found") {
+		t.Errorf("missing package: got %v", err)
+	}
+}
+
+func TestFindManifestVersionMislabelled(t *testing.T) {
+	r := &Registry{Name: "test", Path: t.TempDir()}
+	writeManifests(t, r.Path, "tool", map[string]string{
+		"tool.yaml":         "3.0",
+		"versions/2.0.yaml": "2.5",
+	})
+
+	if _, err := findVersion(r, "tool", "2.0"); err == nil || !strings.Contains(err.Error(), "describes tool v2.5") {
+		t.Errorf("got %v, want a mislabelled version error", err)
+	}
+}
+
+func TestFindManifestVersionHistory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	r := &Registry{Name: "test", Path: t.TempDir()}
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", r.Path, "-c", "user.name=test", "-c", "user.email=test@example.invalid"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+
+	git("init", "-q")
+	for _, ver := range []string{"1.0", "1.1", "2.0", "3.0"} {
+		writeManifests(t, r.Path, "tool", map[string]string{"tool.yaml": ver})
+		git("add", "-A")
+		git("commit", "-q", "-m", "tool "+ver)
+	}
+	writeManifests(t, r.Path, "tool", map[string]string{"versions/2.5.yaml": "2.5"})
+
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr string
+	}{
+		{spec: "3", want: "3.0"},
+		{spec: "^2", want: "2.5"}, // versions/ is preferred over the history
+		{spec: "~2.0", want: "2.0"},
+		{spec: "^1", want: "1.1"},
+		{spec: "1.0", want: "1.0"},
+		{spec: "4", wantErr: "no version of 'tool' matching 4 in registry test (available: 3.0, 2.5)"},
+	}
+
+	for _, tt := range tests {
+		got, err := findVersion(r, "tool", tt.spec)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: got %s, %v, want error %q", tt.spec, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %s, %v, want %s", tt.spec, got, err, tt.want)
+		}
+	}
+}
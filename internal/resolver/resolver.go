@@ -0,0 +1,138 @@
+package resolver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/manifest"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/version"
+)
+
+// Lookup finds the manifest for a package by name: the highest version that
+// satisfies constraint, or the latest version if constraint is nil
+type Lookup func(name string, constraint *version.Constraint) (*manifest.Manifest, error)
+
+// Installed returns the installed version of a package, if any
+type Installed func(name string) (string, bool)
+
+// Step is a single package to install, in dependency order
+type Step struct {
+	Manifest *manifest.Manifest
+
+	// Dependency is true if the package is only installed because
+	// another package needs it
+	Dependency bool
+}
+
+const (
+	unvisited = iota
+	visiting
+	visited
+)
+
+type resolver struct {
+	lookup    Lookup
+	installed Installed
+	roots     map[string]bool
+	manifests map[string]*manifest.Manifest
+	marks     map[string]int
+	plan      []Step
+}
+
+// Resolve returns the roots and every dependency that still needs to be
+// installed, ordered so that each package comes after its dependencies.
+// Dependencies that are already installed at a version satisfying every
+// constraint are left out. Dependency cycles are reported as errors.
+func Resolve(roots []*manifest.Manifest, lookup Lookup, installed Installed) ([]Step, error) {
+	r := &resolver{
+		lookup:    lookup,
+		installed: installed,
+		roots:     make(map[string]bool),
+		manifests: make(map[string]*manifest.Manifest),
+		marks:     make(map[string]int),
+	}
+
+	for _, m := range roots {
+		r.roots[m.Name] = true
+		r.manifests[m.Name] = m
+	}
+
+	for _, m := range roots {
+		if err := r.visit(m, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.plan, nil
+}
+
+func (r *resolver) visit(m *manifest.Manifest, path []string) error {
+	switch r.marks[m.Name] {
+	case visited:
+		return nil
+	case visiting:
+		return cycleError(path, m.Name)
+	}
+
+	r.marks[m.Name] = visiting
+	path = append(path, m.Name)
+
+	for _, dep := range m.Depends {
+		var constraint *version.Constraint
+		if dep.Version != "" {
+			c, err := version.ParseConstraint(dep.Version)
+			if err != nil {
+				return fmt.Errorf("%s: dependency %s: %w", m.Name, dep.Name, err)
+			}
+			constraint = c
+		}
+
+		// An installed dependency that satisfies the constraint needs no work,
+		// unless it was also requested explicitly
+		if v, ok := r.installed(dep.Name); ok && !r.roots[dep.Name] {
+			if constraint == nil || constraint.CheckString(v) {
+				continue
+			}
+		}
+
+		dm, ok := r.manifests[dep.Name]
+		if !ok {
+			found, err := r.lookup(dep.Name, constraint)
+			if err != nil {
+				return fmt.Errorf("%s depends on %s: %w", m.Name, dep.Name, err)
+			}
+			dm = found
+			r.manifests[dep.Name] = dm
+		}
+
+		if constraint != nil && !constraint.CheckString(dm.Version) {
+			return fmt.Errorf("%s requires %s, but v%s is to be installed",
+				m.Name, dep, dm.Version)
+		}
+
+		if err := r.visit(dm, path); err != nil {
+			return err
+		}
+	}
+
+	r.marks[m.Name] = visited
+	r.plan = append(r.plan, Step{
+		Manifest:   m,
+		Dependency: !r.roots[m.Name],
+	})
+	return nil
+}
+
+// cycleError describes the cycle ending at name, e.g. "a -> b -> c -> a"
+func cycleError(path []string, name string) error {
+	start := 0
+	for idx, p := range path {
+		if p == name {
+			start = idx
+			break
+		}
+	}
+
+	cycle := append(append([]string{}, path[start:]...), name)
+	return fmt.Errorf("dependency cycle detected: %s", strings.Join(cycle, " -> "))
+}
@@ -0,0 +1,194 @@
+package resolver
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/manifest"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/version"
+)
+
+// pkg returns a manifest for name at ver with dependencies such as "lib" or
+// "lib >=2"
+func pkg(name, ver string, depends ...string) *manifest.Manifest {
+	m := &manifest.Manifest{Name: name, Version: ver}
+	for _, dep := range depends {
+		d := manifest.Dependency{Name: dep}
+		if n, c, ok := strings.Cut(dep, " "); ok {
+			d = manifest.Dependency{Name: n, Version: c}
+		}
+		m.Depends = append(m.Depends, d)
+	}
+	return m
+}
+
+// registry returns a Lookup over manifests, picking the highest version
+// that satisfies the constraint
+func registry(manifests ...*manifest.Manifest) Lookup {
+	return func(name string, constraint *version.Constraint) (*manifest.Manifest, error) {
+		var best *manifest.Manifest
+		for _, m := range manifests {
+			if m.Name != name || (constraint != nil && !constraint.CheckString(m.Version)) {
+				continue
+			}
+			if best == nil || version.Compare(m.Version, best.Version) > 0 {
+				best = m
+			}
+		}
+		if best == nil {
+			return nil, fmt.Errorf("package %s not found", name)
+		}
+		return best, nil
+	}
+}
+
+// installed returns an Installed over name=version pairs
+func installed(versions map[string]string) Installed {
+	return func(name string) (string, bool) {
+		v, ok := versions[name]
+		return v, ok
+	}
+}
+
+// plan formats a plan as "name@version" entries, dependencies marked "+"
+func plan(steps []Step) string {
+	var parts []string
+	for _, step := range steps {
+		s := step.Manifest.Name + "@" + step.Manifest.Version
+		if step.Dependency {
+			s = "+" + s
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name      string
+		roots     []*manifest.Manifest
+		registry  []*manifest.Manifest
+		installed map[string]string
+		want      string
+	}{
+		{
+			name:  "no dependencies",
+			roots: []*manifest.Manifest{pkg("app", "1.0")},
+			want:  "app@1.0",
+		},
+		{
+			name:     "diamond",
+			roots:    []*manifest.Manifest{pkg("app", "1.0", "a", "b")},
+			registry: []*manifest.Manifest{pkg("a", "1.0", "base"), pkg("b", "1.0", "base"), pkg("base", "1.0")},
+			want:     "+base@1.0 +a@1.0 +b@1.0 app@1.0",
+		},
+		{
+			name:     "chain",
+			roots:    []*manifest.Manifest{pkg("app", "1.0", "a")},
+			registry: []*manifest.Manifest{pkg("a", "1.0", "b"), pkg("b", "1.0", "c"), pkg("c", "1.0")},
+			want:     "+c@1.0 +b@1.0 +a@1.0 app@1.0",
+		},
+		{
+			name:     "highest version within the constraint",
+			roots:    []*manifest.Manifest{pkg("app", "1.0", "lib ^1.2")},
+			registry: []*manifest.Manifest{pkg("lib", "1.1"), pkg("lib", "1.4"), pkg("lib", "2.0")},
+			want:     "+lib@1.4 app@1.0",
+		},
+		{
+			name:      "installed dependency satisfying the constraint",
+			roots:     []*manifest.Manifest{pkg("app", "1.0", "lib >=1.0")},
+			registry:  []*manifest.Manifest{pkg("lib", "2.0")},
+			installed: map[string]string{"lib": "1.5"},
+			want:      "app@1.0",
+		},
+		{
+			name:      "installed dependency outside the constraint",
+			roots:     []*manifest.Manifest{pkg("app", "1.0", "lib >=2.0")},
+			registry:  []*manifest.Manifest{pkg("lib", "2.0")},
+			installed: map[string]string{"lib": "1.5"},
+			want:      "+lib@2.0 app@1.0",
+		},
+		{
+			name:     "root that is also a dependency",
+			roots:    []*manifest.Manifest{pkg("app", "1.0", "lib"), pkg("lib", "1.0")},
+			registry: []*manifest.Manifest{pkg("lib", "2.0")},
+			want:     "lib@1.0 app@1.0",
+		},
+		{
+			name:     "shared dependency of several roots",
+			roots:    []*manifest.Manifest{pkg("a", "1.0", "lib"), pkg("b", "1.0", "lib")},
+			registry: []*manifest.Manifest{pkg("lib", "1.0")},
+			want:     "+lib@1.0 a@1.0 b@1.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, err := Resolve(tt.roots, registry(tt.registry...), installed(tt.installed))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := plan(steps); got != tt.want {
+				t.Errorf("plan = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		roots    []*manifest.Manifest
+		registry []*manifest.Manifest
+		want     string
+	}{
+		{
+			name:     "cycle",
+			roots:    []*manifest.Manifest{pkg("a", "1.0", "b")},
+			registry: []*manifest.Manifest{pkg("b", "1.0", "c"), pkg("c", "1.0", "a")},
+			want:     "dependency cycle detected: a -> b -> c -> a",
+		},
+		{
+			name:     "cycle below the root",
+			roots:    []*manifest.Manifest{pkg("app", "1.0", "a")},
+			registry: []*manifest.Manifest{pkg("a", "1.0", "b"), pkg("b", "1.0", "a")},
+			want:     "dependency cycle detected: a -> b -> a",
+		},
+		{
+			name:     "unknown dependency",
+			roots:    []*manifest.Manifest{pkg("app", "1.0", "a")},
+			registry: []*manifest.Manifest{pkg("a", "1.0", "missing")},
+			want:     "a depends on missing: package missing not found",
+		},
+		{
+			name:     "no version within the constraint",
+			roots:    []*manifest.Manifest{pkg("app", "1.0", "lib >=3")},
+			registry: []*manifest.Manifest{pkg("lib", "2.0")},
+			want:     "app depends on lib: package lib not found",
+		},
+		{
+			name:     "conflicting constraints",
+			roots:    []*manifest.Manifest{pkg("app", "1.0", "a", "lib <2")},
+			registry: []*manifest.Manifest{pkg("a", "1.0", "lib >=2"), pkg("lib", "1.0"), pkg("lib", "2.0")},
+			want:     "app requires lib <2, but v2.0 is to be installed",
+		},
+		{
+			name:  "invalid constraint",
+			roots: []*manifest.Manifest{pkg("app", "1.0", "lib >>2")},
+			want:  "app: dependency lib",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, err := Resolve(tt.roots, registry(tt.registry...), installed(nil))
+			if err == nil {
+				t.Fatalf("got plan %q, want an error", plan(steps))
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
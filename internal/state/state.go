@@ -5,23 +5,64 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
+
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/util"
+	"github.com/Foggy-Forge/git-bash-package-manager/internal/version"
 )
 
 // State represents the gbpm state
 type State struct {
 	Installed map[string]*Package `json:"installed"`
+
+	// Pins holds packages frozen at a version or constraint by 'gbpm pin'.
+	// Pins outlive uninstalls, so a reinstall honours them too.
+	Pins map[string]string `json:"pins,omitempty"`
 }
 
 // Package represents an installed package
 type Package struct {
-	Name        string    `json:"name"`
-	Version     string    `json:"version"`
-	Files       []string  `json:"files"`
-	InstalledAt time.Time `json:"installed_at"`
+	Name         string    `json:"name"`
+	Version      string    `json:"version"`
+	Registry     string    `json:"registry,omitempty"`
+	Files        []string  `json:"files"`
+	Dependencies []string  `json:"dependencies,omitempty"`
+	AsDependency bool      `json:"as_dependency,omitempty"`
+	InstalledAt  time.Time `json:"installed_at"`
+
+	// Kept marks a version installed side by side with --keep, which is never
+	// removed to make room for versions retained for rollback
+	Kept bool `json:"kept,omitempty"`
+
+	// Inactive holds other installed versions of the package. Their files
+	// outside the version's app directory are parked until they are switched
+	// back in; the fields above describe the active version.
+	Inactive []*Package `json:"inactive,omitempty"`
+}
+
+// InactiveVersion returns the inactive installed version of the package
+// equal to ver, so "1.0" finds "1.0.0"
+func (p *Package) InactiveVersion(ver string) (*Package, bool) {
+	for _, v := range p.Inactive {
+		if version.Compare(v.Version, ver) == 0 {
+			return v, true
+		}
+	}
+	return nil, false
 }
 
-// Load loads the state from the state file
+// InstalledVersions returns every installed version, the active one first
+func (p *Package) InstalledVersions() []string {
+	versions := []string{p.Version}
+	for _, v := range p.Inactive {
+		versions = append(versions, v.Version)
+	}
+	return versions
+}
+
+// Load loads the state from the state file. If the state file cannot be
+// read or parsed, the backup of the last good state is used instead.
 func Load(statePath string) (*State, error) {
 	// If file doesn't exist, return empty state
 	if _, err := os.Stat(statePath); os.IsNotExist(err) {
@@ -30,6 +71,20 @@ func Load(statePath string) (*State, error) {
 		}, nil
 	}
 
+	s, err := load(statePath)
+	if err != nil {
+		backup, backupErr := load(BackupPath(statePath))
+		if backupErr != nil {
+			return nil, err
+		}
+		fmt.Printf("Warning: %v, using backup %s\n", err, BackupPath(statePath))
+		s = backup
+	}
+
+	return s, nil
+}
+
+func load(statePath string) (*State, error) {
 	data, err := os.ReadFile(statePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read state file: %w", err)
@@ -47,7 +102,14 @@ func Load(statePath string) (*State, error) {
 	return &s, nil
 }
 
-// Save saves the state to the state file
+// BackupPath returns where the last good state is kept
+func BackupPath(statePath string) string {
+	return statePath + ".bak"
+}
+
+// Save saves the state to the state file. The new state is written to a
+// temporary file and renamed over the state file, so a crash never leaves a
+// truncated state behind, and the previous state is kept as a backup.
 func (s *State) Save(statePath string) error {
 	// Create parent directory
 	if err := os.MkdirAll(filepath.Dir(statePath), 0755); err != nil {
@@ -59,7 +121,13 @@ func (s *State) Save(statePath string) error {
 		return fmt.Errorf("failed to marshal state: %w", err)
 	}
 
-	if err := os.WriteFile(statePath, data, 0644); err != nil {
+	if previous, err := os.ReadFile(statePath); err == nil && json.Valid(previous) {
+		if err := util.WriteFileAtomic(BackupPath(statePath), previous); err != nil {
+			return fmt.Errorf("failed to back up state file: %w", err)
+		}
+	}
+
+	if err := util.WriteFileAtomic(statePath, data); err != nil {
 		return fmt.Errorf("failed to write state file: %w", err)
 	}
 
@@ -90,3 +158,76 @@ func (s *State) IsInstalled(name string) bool {
 	_, ok := s.Installed[name]
 	return ok
 }
+
+// Pin pins a package to a version or constraint
+func (s *State) Pin(name, constraint string) {
+	if s.Pins == nil {
+		s.Pins = make(map[string]string)
+	}
+	s.Pins[name] = constraint
+}
+
+// Unpin removes the pin of a package, reporting whether it was pinned
+func (s *State) Unpin(name string) bool {
+	if _, ok := s.Pins[name]; !ok {
+		return false
+	}
+	delete(s.Pins, name)
+	return true
+}
+
+// GetPin returns the version or constraint a package is pinned to
+func (s *State) GetPin(name string) (string, bool) {
+	pin, ok := s.Pins[name]
+	return pin, ok
+}
+
+// Dependents returns the names of installed packages that depend on name
+func (s *State) Dependents(name string) []string {
+	var dependents []string
+	for _, pkg := range s.Installed {
+		for _, dep := range pkg.Dependencies {
+			if dep == name {
+				dependents = append(dependents, pkg.Name)
+				break
+			}
+		}
+	}
+	sort.Strings(dependents)
+	return dependents
+}
+
+// Orphans returns the names of packages that were installed as dependencies
+// but are no longer required, directly or transitively, by any package that
+// was installed explicitly
+func (s *State) Orphans() []string {
+	required := make(map[string]bool)
+
+	var mark func(name string)
+	mark = func(name string) {
+		if required[name] {
+			return
+		}
+		required[name] = true
+		if pkg, ok := s.Installed[name]; ok {
+			for _, dep := range pkg.Dependencies {
+				mark(dep)
+			}
+		}
+	}
+
+	for name, pkg := range s.Installed {
+		if !pkg.AsDependency {
+			mark(name)
+		}
+	}
+
+	var orphans []string
+	for name := range s.Installed {
+		if !required[name] {
+			orphans = append(orphans, name)
+		}
+	}
+	sort.Strings(orphans)
+	return orphans
+}
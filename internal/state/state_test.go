@@ -0,0 +1,107 @@
+package state
+
+import (
+	"strings"
+	"testing"
+)
+
+// pkg returns an installed package depending on deps
+func pkg(name string, asDependency bool, deps ...string) *Package {
+	return &Package{Name: name, Version: "1.0", AsDependency: asDependency, Dependencies: deps}
+}
+
+func TestOrphans(t *testing.T) {
+	tests := []struct {
+		name     string
+		packages []*Package
+		want     string
+	}{
+		{
+			name:     "nothing installed",
+			packages: nil,
+			want:     "",
+		},
+		{
+			name:     "explicit packages are never orphans",
+			packages: []*Package{pkg("app", false), pkg("tool", false)},
+			want:     "",
+		},
+		{
+			name:     "unused dependency",
+			packages: []*Package{pkg("app", false), pkg("lib", true)},
+			want:     "lib",
+		},
+		{
+			name: "transitive dependencies are required",
+			packages: []*Package{
+				pkg("app", false, "lib"),
+				pkg("lib", true, "base"),
+				pkg("base", true),
+			},
+			want: "",
+		},
+		{
+			name: "dependencies of orphans are orphans",
+			packages: []*Package{
+				pkg("lib", true, "base"),
+				pkg("base", true),
+				pkg("other", false),
+			},
+			want: "base,lib",
+		},
+		{
+			name: "dependency cycles without an explicit package",
+			packages: []*Package{
+				pkg("a", true, "b"),
+				pkg("b", true, "a"),
+			},
+			want: "a,b",
+		},
+		{
+			name: "promoted to explicit keeps its dependencies",
+			packages: []*Package{
+				pkg("lib", false, "base"),
+				pkg("base", true),
+				pkg("unused", true),
+			},
+			want: "unused",
+		},
+		{
+			name: "dependency on a missing package",
+			packages: []*Package{
+				pkg("app", false, "gone"),
+				pkg("lib", true),
+			},
+			want: "lib",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &State{Installed: make(map[string]*Package)}
+			for _, p := range tt.packages {
+				s.AddPackage(p)
+			}
+			if got := strings.Join(s.Orphans(), ","); got != tt.want {
+				t.Errorf("Orphans() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDependents(t *testing.T) {
+	s := &State{Installed: make(map[string]*Package)}
+	for _, p := range []*Package{pkg("b", false, "lib"), pkg("a", false, "lib", "base"), pkg("lib", true, "base")} {
+		s.AddPackage(p)
+	}
+
+	if got := strings.Join(s.Dependents("lib"), ","); got != "a,b" {
+		t.Errorf("Dependents(lib) = %q, want a,b", got)
+	}
+	if got := strings.Join(s.Dependents("base"), ","); got != "a,lib" {
+		t.Errorf("Dependents(base) = %q, want a,lib", got)
+	}
+	if got := s.Dependents("a"); len(got) != 0 {
+		t.Errorf("Dependents(a) = %v, want none", got)
+	}
+}
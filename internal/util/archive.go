@@ -8,19 +8,23 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"strings"
 )
 
 // ExtractZip extracts a zip archive to the destination directory
 func ExtractZip(src, dest string) error {
+	return extractZip(src, dest, ExtractOptions{})
+}
+
+func extractZip(src, dest string, opts ExtractOptions) error {
 	r, err := zip.OpenReader(src)
 	if err != nil {
 		return fmt.Errorf("failed to open zip: %w", err)
 	}
 	defer r.Close()
 
+	guard := newExtractGuard(src)
 	for _, f := range r.File {
-		if err := extractZipFile(f, dest); err != nil {
+		if err := extractZipFile(f, dest, opts, guard); err != nil {
 			return err
 		}
 	}
@@ -28,27 +32,42 @@ func ExtractZip(src, dest string) error {
 	return nil
 }
 
-func extractZipFile(f *zip.File, dest string) error {
-	// Construct file path
-	fpath := filepath.Join(dest, f.Name)
+func extractZipFile(f *zip.File, dest string, opts ExtractOptions, guard *extractGuard) error {
+	if err := guard.entry(f.Name); err != nil {
+		return err
+	}
 
-	// Check for ZipSlip vulnerability
-	if !strings.HasPrefix(fpath, filepath.Clean(dest)+string(os.PathSeparator)) {
-		return fmt.Errorf("illegal file path: %s", fpath)
+	// Construct file path, rejecting absolute and escaping paths (ZipSlip)
+	name, err := entryName(f.Name)
+	if err != nil || name == "" {
+		return err
+	}
+	name, ok := opts.apply(name)
+	if !ok {
+		return nil
+	}
+	fpath := filepath.Join(dest, filepath.FromSlash(name))
+
+	if err := checkMode(f.Mode(), f.Name); err != nil {
+		return err
 	}
 
 	if f.FileInfo().IsDir() {
 		// Create directory
-		return os.MkdirAll(fpath, os.ModePerm)
+		return os.MkdirAll(fpath, 0755)
+	}
+
+	if err := guard.reserve(int64(f.UncompressedSize64), f.Name); err != nil {
+		return err
 	}
 
 	// Create parent directory
-	if err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
+	if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
 		return err
 	}
 
 	// Create file
-	outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
+	outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileMode(f.Mode()))
 	if err != nil {
 		return err
 	}
@@ -57,13 +76,15 @@ func extractZipFile(f *zip.File, dest string) error {
 	// Open file in archive
 	rc, err := f.Open()
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to open %s: %w", f.Name, err)
 	}
 	defer rc.Close()
 
 	// Copy contents
-	_, err = io.Copy(outFile, rc)
-	return err
+	if err := guard.copy(outFile, rc, f.Name); err != nil {
+		return err
+	}
+	return outFile.Close()
 }
 
 // ExtractTarGz extracts a tar.gz archive to the destination directory
@@ -80,7 +101,39 @@ func ExtractTarGz(src, dest string) error {
 	}
 	defer gzr.Close()
 
-	tr := tar.NewReader(gzr)
+	return extractTar(gzr, dest, ExtractOptions{}, newExtractGuard(src))
+}
+
+// ExtractTar extracts a tar archive, optionally compressed with gzip, xz,
+// bzip2 or zstd, to the destination directory
+func ExtractTar(src, dest string) error {
+	return extractTarFile(src, dest, ExtractOptions{})
+}
+
+func extractTarFile(src, dest string, opts ExtractOptions) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open tar: %w", err)
+	}
+	defer file.Close()
+
+	r, err := decompress(file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return extractTar(r, dest, opts, newExtractGuard(src))
+}
+
+// extractTar extracts an uncompressed tar stream to the destination directory
+func extractTar(r io.Reader, dest string, opts ExtractOptions, guard *extractGuard) error {
+	tr := tar.NewReader(r)
+
+	links, err := newLinkExtractor(dest, guard)
+	if err != nil {
+		return err
+	}
 
 	for {
 		header, err := tr.Next()
@@ -91,33 +144,70 @@ func ExtractTarGz(src, dest string) error {
 			return fmt.Errorf("failed to read tar: %w", err)
 		}
 
-		// Construct file path
-		fpath := filepath.Join(dest, header.Name)
+		if err := guard.entry(header.Name); err != nil {
+			return err
+		}
+
+		// Construct file path, rejecting absolute and escaping paths (ZipSlip)
+		name, err := entryName(header.Name)
+		if err != nil {
+			return err
+		}
+		name, ok := opts.apply(name)
+		if name == "" || !ok {
+			continue
+		}
+		fpath := filepath.Join(dest, filepath.FromSlash(name))
 
-		// Check for ZipSlip vulnerability
-		if !strings.HasPrefix(fpath, filepath.Clean(dest)+string(os.PathSeparator)) {
-			return fmt.Errorf("illegal file path: %s", fpath)
+		if err := checkMode(header.FileInfo().Mode(), header.Name); err != nil {
+			return err
+		}
+
+		if err := links.checkPath(fpath, header.Name); err != nil {
+			return err
 		}
 
 		switch header.Typeflag {
 		case tar.TypeDir:
-			if err := os.MkdirAll(fpath, os.ModePerm); err != nil {
+			if err := os.MkdirAll(fpath, 0755); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := links.symlink(fpath, header.Linkname, header.Name); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			target, err := entryName(header.Linkname)
+			if err != nil || target == "" {
+				return fmt.Errorf("illegal link: %s points outside the destination (%s)", header.Name, header.Linkname)
+			}
+			// The target may have been stripped or filtered out
+			target, ok := opts.apply(target)
+			if !ok {
+				fmt.Printf("Warning: skipping link %s: its target %s is not extracted\n", header.Name, header.Linkname)
+				continue
+			}
+			if err := links.hardlink(fpath, target, header.Name); err != nil {
 				return err
 			}
 		case tar.TypeReg:
+			if err := guard.reserve(header.Size, header.Name); err != nil {
+				return err
+			}
+
 			// Create parent directory
-			if err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
+			if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
 				return err
 			}
 
 			// Create file
-			outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(header.Mode))
+			outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileMode(os.FileMode(header.Mode)))
 			if err != nil {
 				return err
 			}
 
 			// Copy contents
-			if _, err := io.Copy(outFile, tr); err != nil {
+			if err := guard.copy(outFile, tr, header.Name); err != nil {
 				outFile.Close()
 				return err
 			}
@@ -125,15 +215,65 @@ func ExtractTarGz(src, dest string) error {
 		}
 	}
 
-	return nil
+	return links.finish()
+}
+
+// DecompressFile decompresses a single gzip, xz, bzip2 or zstd compressed
+// file into the destination directory. The output is named after src with
+// the compression extension removed, e.g. "jq.gz" becomes "jq".
+func DecompressFile(src, dest string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", filepath.Base(src), err)
+	}
+	defer file.Close()
+
+	r, err := decompress(file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+
+	name := decompressedName(src)
+	outFile, err := os.OpenFile(filepath.Join(dest, name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	defer outFile.Close()
+
+	if err := newExtractGuard(src).copy(outFile, r, name); err != nil {
+		return err
+	}
+
+	return outFile.Close()
 }
 
-// Extract automatically detects and extracts an archive
+// Extract detects the archive format from the file contents and extracts
+// it to the destination directory. Single compressed files are decompressed
+// into it.
 func Extract(src, dest string) error {
-	if strings.HasSuffix(src, ".zip") {
-		return ExtractZip(src, dest)
-	} else if strings.HasSuffix(src, ".tar.gz") || strings.HasSuffix(src, ".tgz") {
-		return ExtractTarGz(src, dest)
+	return ExtractWith(src, dest, ExtractOptions{})
+}
+
+// ExtractWith extracts like Extract, stripping and filtering zip and tar
+// entries according to opts
+func ExtractWith(src, dest string, opts ExtractOptions) error {
+	format, err := DetectFormat(src)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatZip:
+		return extractZip(src, dest, opts)
+	case FormatTar, FormatTarGz, FormatTarXz, FormatTarBz2, FormatTarZst:
+		return extractTarFile(src, dest, opts)
+	case FormatGz, FormatXz, FormatBz2, FormatZst:
+		return DecompressFile(src, dest)
 	}
-	return fmt.Errorf("unsupported archive format: %s", src)
+	return fmt.Errorf("unsupported archive format: %s", filepath.Base(src))
 }
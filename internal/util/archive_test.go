@@ -0,0 +1,299 @@
+package util
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tarEntry describes an entry written by writeTar; without a type it is a
+// regular file
+type tarEntry struct {
+	name     string
+	typeflag byte
+	linkname string
+	body     string
+	mode     int64
+}
+
+// writeTar writes an uncompressed tarball of entries and returns its path
+func writeTar(t *testing.T, entries []tarEntry) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.tar")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	for _, e := range entries {
+		h := &tar.Header{
+			Name:     e.name,
+			Typeflag: e.typeflag,
+			Linkname: e.linkname,
+			Mode:     e.mode,
+			Size:     int64(len(e.body)),
+		}
+		if h.Typeflag == 0 {
+			h.Typeflag = tar.TypeReg
+		}
+		if h.Mode == 0 {
+			h.Mode = 0644
+		}
+		if h.Typeflag != tar.TypeReg {
+			h.Size = 0
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if h.Size > 0 {
+			if _, err := tw.Write([]byte(e.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeZip writes a zip archive of files, by name, and returns its path
+func writeZip(t *testing.T, names ...string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// canSymlink skips the test where symlinks cannot be created
+func canSymlink(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.Symlink("target", filepath.Join(dir, "link")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+}
+
+func TestExtractZipSlip(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry string
+		want  string
+	}{
+		{name: "parent", entry: "../evil", want: "outside the destination"},
+		{name: "nested parent", entry: "bin/../../evil", want: "outside the destination"},
+		{name: "absolute", entry: "/evil", want: "absolute"},
+		{name: "drive", entry: "C:/evil", want: "absolute"},
+	}
+
+	for _, tt := range tests {
+		t.Run("zip "+tt.name, func(t *testing.T) {
+			parent := t.TempDir()
+			dest := filepath.Join(parent, "dest")
+			err := Extract(writeZip(t, "ok", tt.entry), dest)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got %v, want an error mentioning %q", err, tt.want)
+			}
+			if _, err := os.Stat(filepath.Join(parent, "evil")); err == nil {
+				t.Fatal("entry written outside the destination")
+			}
+		})
+
+		t.Run("tar "+tt.name, func(t *testing.T) {
+			parent := t.TempDir()
+			dest := filepath.Join(parent, "dest")
+			err := Extract(writeTar(t, []tarEntry{{name: "ok"}, {name: tt.entry, body: "x"}}), dest)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got %v, want an error mentioning %q", err, tt.want)
+			}
+			if _, err := os.Stat(filepath.Join(parent, "evil")); err == nil {
+				t.Fatal("entry written outside the destination")
+			}
+		})
+	}
+}
+
+func TestExtractLinkEscapes(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		want    string
+	}{
+		{
+			name:    "absolute symlink",
+			entries: []tarEntry{{name: "passwd", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"}},
+			want:    "absolute path",
+		},
+		{
+			name:    "escaping symlink",
+			entries: []tarEntry{{name: "bin/up", typeflag: tar.TypeSymlink, linkname: "../../evil"}},
+			want:    "outside the destination",
+		},
+		{
+			name:    "escaping hardlink",
+			entries: []tarEntry{{name: "passwd", typeflag: tar.TypeLink, linkname: "../etc/passwd"}},
+			want:    "outside the destination",
+		},
+		{
+			name:    "absolute hardlink",
+			entries: []tarEntry{{name: "passwd", typeflag: tar.TypeLink, linkname: "/etc/passwd"}},
+			want:    "outside the destination",
+		},
+		{
+			name:    "device file",
+			entries: []tarEntry{{name: "null", typeflag: tar.TypeChar}},
+			want:    "device file",
+		},
+		{
+			name:    "setuid file",
+			entries: []tarEntry{{name: "su", body: "x", mode: 04755}},
+			want:    "setuid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Extract(writeTar(t, tt.entries), t.TempDir())
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractLinks(t *testing.T) {
+	canSymlink(t)
+
+	dest := t.TempDir()
+	src := writeTar(t, []tarEntry{
+		{name: "pkg/bin/tool", body: "tool", mode: 0755},
+		{name: "pkg/tool", typeflag: tar.TypeSymlink, linkname: "bin/tool"},
+		{name: "pkg/tool-copy", typeflag: tar.TypeLink, linkname: "pkg/bin/tool"},
+	})
+	if err := Extract(src, dest); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"pkg/tool", "pkg/tool-copy"} {
+		data, err := os.ReadFile(filepath.Join(dest, name))
+		if err != nil || string(data) != "tool" {
+			t.Errorf("%s = %q, %v, want the contents of pkg/bin/tool", name, data, err)
+		}
+	}
+}
+
+func TestExtractThroughOutsideLink(t *testing.T) {
+	canSymlink(t)
+
+	outside := t.TempDir()
+	dest := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dest, "out")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writing through a symlink that leads outside the destination
+	err := Extract(writeTar(t, []tarEntry{{name: "out/evil", body: "x"}}), dest)
+	if err == nil || !strings.Contains(err.Error(), "through a link outside") {
+		t.Errorf("write through link: got %v", err)
+	}
+
+	// A symlink that only resolves outside through that link
+	err = Extract(writeTar(t, []tarEntry{{name: "evil", typeflag: tar.TypeSymlink, linkname: "out"}}), dest)
+	if err == nil || !strings.Contains(err.Error(), "resolves outside") {
+		t.Errorf("symlink through link: got %v", err)
+	}
+
+	if entries, _ := os.ReadDir(outside); len(entries) > 0 {
+		t.Errorf("%d file(s) written outside the destination", len(entries))
+	}
+}
+
+// newTestLinks returns a link extractor for a fresh destination holding
+// dir/file, for testing the copies that replace symlinks
+func newTestLinks(t *testing.T, size int) (*linkExtractor, string) {
+	t.Helper()
+
+	dest := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dest, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dest, "dir", "file"), []byte(strings.Repeat("x", size)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := newLinkExtractor(dest, &extractGuard{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l, dest
+}
+
+func TestLinkCopies(t *testing.T) {
+	l, dest := newTestLinks(t, 10)
+	l.copies = []pendingLink{
+		{path: filepath.Join(dest, "chained"), target: filepath.Join(dest, "copy"), name: "chained"},
+		{path: filepath.Join(dest, "copy"), target: filepath.Join(dest, "dir"), name: "copy"},
+	}
+	if err := l.finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"copy/file", "chained/file"} {
+		if _, err := os.Stat(filepath.Join(dest, name)); err != nil {
+			t.Errorf("%s not copied: %v", name, err)
+		}
+	}
+}
+
+func TestLinkCopySelfContaining(t *testing.T) {
+	l, dest := newTestLinks(t, 10)
+	l.copies = []pendingLink{
+		{path: filepath.Join(dest, "dir", "loop"), target: filepath.Join(dest, "dir"), name: "dir/loop"},
+	}
+
+	err := l.finish()
+	if err == nil || !strings.Contains(err.Error(), "containing itself") {
+		t.Fatalf("got %v, want a self-containing link error", err)
+	}
+}
+
+func TestLinkCopyLimits(t *testing.T) {
+	defer func(size int64) { ExtractMaxSize = size }(ExtractMaxSize)
+	ExtractMaxSize = 2500
+
+	// Each copy of dir adds another 1000 bytes
+	l, dest := newTestLinks(t, 1000)
+	for _, name := range []string{"l1", "l2", "l3"} {
+		l.copies = append(l.copies, pendingLink{path: filepath.Join(dest, name), target: filepath.Join(dest, "dir"), name: name})
+	}
+
+	err := l.finish()
+	if err == nil || !strings.Contains(err.Error(), "archive too large") {
+		t.Fatalf("got %v, want the size limit to stop the copies", err)
+	}
+}
@@ -0,0 +1,189 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// Credential holds the authentication applied to requests for hosts
+// matching Host, a hostname or a glob such as "*.example.com".
+//
+// A Token is sent as "Authorization: Bearer <token>", or as the raw value of
+// Header when set (e.g. "X-JFrog-Art-Api"). Username and Password are sent
+// as basic auth instead.
+type Credential struct {
+	Host     string `json:"host"`
+	Token    string `json:"token,omitempty"`
+	Header   string `json:"header,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// String describes the credential without revealing secrets
+func (c Credential) String() string {
+	switch {
+	case c.Username != "":
+		return fmt.Sprintf("%s (basic auth as %s)", c.Host, c.Username)
+	case c.Header != "":
+		return fmt.Sprintf("%s (%s header)", c.Host, c.Header)
+	default:
+		return fmt.Sprintf("%s (bearer token)", c.Host)
+	}
+}
+
+// GoString keeps secrets out of %#v output
+func (c Credential) GoString() string {
+	return "util.Credential{" + c.String() + "}"
+}
+
+// Matches reports whether the credential applies to host
+func (c Credential) Matches(host string) bool {
+	host = strings.ToLower(host)
+	pattern := strings.ToLower(c.Host)
+	if pattern == host {
+		return true
+	}
+	ok, err := path.Match(pattern, host)
+	return err == nil && ok
+}
+
+// apply adds the credential to req
+func (c Credential) apply(req *http.Request) {
+	switch {
+	case c.Username != "":
+		req.SetBasicAuth(c.Username, c.Password)
+	case c.Header != "":
+		req.Header.Set(c.Header, c.Token)
+	default:
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+}
+
+// credentialsFile is the on-disk format of the credentials file
+type credentialsFile struct {
+	Credentials []Credential `json:"credentials"`
+}
+
+// LoadCredentials reads per-host credentials from a JSON file of the form
+//
+//	{"credentials": [{"host": "artifacts.example.com", "token": "..."}]}
+//
+// A missing file yields no credentials.
+func LoadCredentials(path string) ([]Credential, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+
+	var f credentialsFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		// Don't wrap the JSON error, it may quote part of a secret
+		return nil, fmt.Errorf("failed to parse credentials file %s", path)
+	}
+
+	for idx, c := range f.Credentials {
+		if c.Host == "" {
+			return nil, fmt.Errorf("credentials file %s: entry %d has no host", path, idx+1)
+		}
+		if c.Token == "" && c.Username == "" {
+			return nil, fmt.Errorf("credentials file %s: entry for %s has no token or username", path, c.Host)
+		}
+	}
+
+	return f.Credentials, nil
+}
+
+// GitHubCredentialsFromEnv returns credentials for github.com and its API
+// from GBPM_GITHUB_TOKEN, falling back to GITHUB_TOKEN
+func GitHubCredentialsFromEnv() []Credential {
+	token := os.Getenv("GBPM_GITHUB_TOKEN")
+	if token == "" {
+		token = os.Getenv("GITHUB_TOKEN")
+	}
+	if token == "" {
+		return nil
+	}
+
+	return []Credential{
+		{Host: "github.com", Token: token},
+		{Host: "api.github.com", Token: token},
+	}
+}
+
+// authTransport adds credentials to HTTPS requests for matching hosts.
+// Credentials are never sent over plain HTTP. Since it runs for every
+// request, redirects to other hosts only get credentials meant for them.
+type authTransport struct {
+	base        http.RoundTripper
+	credentials []Credential
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" || req.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	host := req.URL.Hostname()
+	for _, c := range t.credentials {
+		if !c.Matches(host) {
+			continue
+		}
+
+		req = req.Clone(req.Context())
+		c.apply(req)
+
+		// GitHub API release asset URLs return JSON metadata unless asked for the file
+		if host == "api.github.com" && strings.Contains(req.URL.Path, "/releases/assets/") &&
+			req.Header.Get("Accept") == "" {
+			req.Header.Set("Accept", "application/octet-stream")
+		}
+		break
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+var sensitiveParams = []string{
+	"token", "access_token", "api_key", "apikey", "key",
+	"sig", "signature", "x-amz-signature", "x-amz-credential", "password",
+}
+
+// RedactURL returns rawURL with any password and sensitive query parameters
+// masked, for printing in logs and errors
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+
+	if u.User != nil {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.UserPassword(u.User.Username(), "xxxxx")
+		} else {
+			// A lone userinfo value is usually a token
+			u.User = url.User("xxxxx")
+		}
+	}
+
+	if u.RawQuery != "" {
+		q := u.Query()
+		for key := range q {
+			for _, s := range sensitiveParams {
+				if strings.EqualFold(key, s) {
+					q.Set(key, "xxxxx")
+				}
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+
+	return u.String()
+}
@@ -0,0 +1,102 @@
+package util
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// ChecksumMismatchError is returned when a file does not match its expected checksum
+type ChecksumMismatchError struct {
+	Path     string
+	Algo     string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %s: expected %s:%s, got %s:%s",
+		e.Path, e.Algo, e.Expected, e.Algo, e.Actual)
+}
+
+// ParseChecksum splits a checksum of the form algo:value and validates it
+func ParseChecksum(checksum string) (algo, value string, err error) {
+	algo, value, ok := strings.Cut(checksum, ":")
+	if !ok {
+		return "", "", fmt.Errorf("invalid checksum %q: expected algo:value", checksum)
+	}
+
+	algo = strings.ToLower(strings.TrimSpace(algo))
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	h, err := newHash(algo)
+	if err != nil {
+		return "", "", err
+	}
+
+	if _, err := hex.DecodeString(value); err != nil || len(value) != h.Size()*2 {
+		return "", "", fmt.Errorf("invalid %s checksum value: %q", algo, value)
+	}
+
+	return algo, value, nil
+}
+
+// FileChecksum computes the hex digest of a file using the given algorithm
+func FileChecksum(path, algo string) (string, error) {
+	h, err := newHash(algo)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// VerifyChecksum checks that a file matches a checksum of the form algo:value
+func VerifyChecksum(path, checksum string) error {
+	algo, expected, err := ParseChecksum(checksum)
+	if err != nil {
+		return err
+	}
+
+	actual, err := FileChecksum(path, algo)
+	if err != nil {
+		return err
+	}
+
+	if actual != expected {
+		return &ChecksumMismatchError{
+			Path:     path,
+			Algo:     algo,
+			Expected: expected,
+			Actual:   actual,
+		}
+	}
+
+	return nil
+}
+
+func newHash(algo string) (hash.Hash, error) {
+	switch algo {
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm: %s", algo)
+	}
+}
@@ -0,0 +1,78 @@
+package util
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Digests of "hello\n"
+const (
+	helloSHA256 = "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+	helloSHA512 = "e7c22b994c59d9cf2b48e549b1e24666636045930d3da7c1acb299d1c3b7f931" +
+		"f94aae41edda2c2b207a36e10f8bcb8d45223e54878f5b316e7ce3b6bc019629"
+)
+
+func TestParseChecksum(t *testing.T) {
+	tests := []struct {
+		in        string
+		algo      string
+		value     string
+		wantError bool
+	}{
+		{in: "sha256:" + helloSHA256, algo: "sha256", value: helloSHA256},
+		{in: "SHA256: " + strings.ToUpper(helloSHA256), algo: "sha256", value: helloSHA256},
+		{in: "sha512:" + helloSHA512, algo: "sha512", value: helloSHA512},
+		{in: helloSHA256, wantError: true},
+		{in: "md5:b1946ac92492d2347c6235b4d2611184", wantError: true},
+		{in: "sha256:" + helloSHA256[:63], wantError: true},
+		{in: "sha256:" + helloSHA512, wantError: true},
+		{in: "sha256:" + strings.Repeat("zz", 32), wantError: true},
+	}
+
+	for _, tt := range tests {
+		algo, value, err := ParseChecksum(tt.in)
+		if tt.wantError {
+			if err == nil {
+				t.Errorf("ParseChecksum(%q) succeeded, want an error", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseChecksum(%q): %v", tt.in, err)
+			continue
+		}
+		if algo != tt.algo || value != tt.value {
+			t.Errorf("ParseChecksum(%q) = %s:%s, want %s:%s", tt.in, algo, value, tt.algo, tt.value)
+		}
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hello")
+	if err := os.WriteFile(path, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, checksum := range []string{"sha256:" + helloSHA256, "sha512:" + helloSHA512} {
+		if err := VerifyChecksum(path, checksum); err != nil {
+			t.Errorf("VerifyChecksum(%s): %v", checksum, err)
+		}
+	}
+
+	wrong := "sha256:" + strings.Repeat("0", 64)
+	err := VerifyChecksum(path, wrong)
+	var mismatch *ChecksumMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("VerifyChecksum(%s) = %v, want a ChecksumMismatchError", wrong, err)
+	}
+	if mismatch.Actual != helloSHA256 {
+		t.Errorf("mismatch reports %s, want %s", mismatch.Actual, helloSHA256)
+	}
+
+	if err := VerifyChecksum(filepath.Join(t.TempDir(), "missing"), "sha256:"+helloSHA256); err == nil {
+		t.Error("VerifyChecksum of a missing file succeeded")
+	}
+}
@@ -0,0 +1,200 @@
+package util
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// HTTPConfig configures the HTTP client shared by every network call
+type HTTPConfig struct {
+	// CABundle is a PEM file of extra CA certificates trusted in addition to
+	// the system ones, e.g. for a TLS-intercepting corporate proxy
+	CABundle string
+
+	// ConnectTimeout bounds establishing a connection, including TLS
+	ConnectTimeout time.Duration
+
+	// ReadTimeout bounds waiting for response headers and for each read of
+	// the response body, so stalled transfers fail without capping the
+	// total time of large downloads
+	ReadTimeout time.Duration
+
+	UserAgent string
+
+	// Credentials are applied to HTTPS requests by host, first match wins
+	Credentials []Credential
+}
+
+// Defaults for HTTPConfig fields left empty
+const (
+	DefaultConnectTimeout = 30 * time.Second
+	DefaultReadTimeout    = 60 * time.Second
+)
+
+var (
+	clientMu sync.Mutex
+	client   *http.Client
+)
+
+// HTTPConfigFromEnv reads the HTTP configuration from the environment:
+// GBPM_CA_BUNDLE, GBPM_CONNECT_TIMEOUT and GBPM_READ_TIMEOUT (durations
+// such as "45s", or plain seconds) and GBPM_USER_AGENT. Proxies are taken
+// from HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+func HTTPConfigFromEnv(defaultUserAgent string) (HTTPConfig, error) {
+	cfg := HTTPConfig{
+		CABundle:  os.Getenv("GBPM_CA_BUNDLE"),
+		UserAgent: defaultUserAgent,
+	}
+
+	if ua := os.Getenv("GBPM_USER_AGENT"); ua != "" {
+		cfg.UserAgent = ua
+	}
+
+	var err error
+	if cfg.ConnectTimeout, err = envDuration("GBPM_CONNECT_TIMEOUT"); err != nil {
+		return cfg, err
+	}
+	if cfg.ReadTimeout, err = envDuration("GBPM_READ_TIMEOUT"); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// ConfigureHTTP builds the shared HTTP client from cfg
+func ConfigureHTTP(cfg HTTPConfig) error {
+	c, err := newHTTPClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	clientMu.Lock()
+	client = c
+	clientMu.Unlock()
+	return nil
+}
+
+// HTTPClient returns the shared HTTP client, with default settings if
+// ConfigureHTTP has not been called
+func HTTPClient() *http.Client {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+
+	if client == nil {
+		client, _ = newHTTPClient(HTTPConfig{})
+	}
+	return client
+}
+
+func newHTTPClient(cfg HTTPConfig) (*http.Client, error) {
+	if cfg.ConnectTimeout <= 0 {
+		cfg.ConnectTimeout = DefaultConnectTimeout
+	}
+	if cfg.ReadTimeout <= 0 {
+		cfg.ReadTimeout = DefaultReadTimeout
+	}
+
+	tlsConfig := &tls.Config{}
+	if cfg.CABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		pem, err := os.ReadFile(cfg.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", cfg.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   cfg.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	readTimeout := cfg.ReadTimeout
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return &deadlineConn{Conn: conn, timeout: readTimeout}, nil
+		},
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   cfg.ConnectTimeout,
+		ResponseHeaderTimeout: readTimeout,
+		ExpectContinueTimeout: time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          10,
+		ForceAttemptHTTP2:     true,
+	}
+
+	return &http.Client{
+		Transport: &userAgentTransport{
+			base: &authTransport{
+				base:        transport,
+				credentials: cfg.Credentials,
+			},
+			userAgent: cfg.UserAgent,
+		},
+	}, nil
+}
+
+// deadlineConn extends the read deadline before every read, turning it into
+// an idle timeout
+type deadlineConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *deadlineConn) Read(p []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(p)
+}
+
+// userAgentTransport sets the User-Agent header on requests that lack one
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.userAgent != "" && req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
+
+func envDuration(key string) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return 0, nil
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, v, err)
+	}
+	return d, nil
+}
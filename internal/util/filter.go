@@ -0,0 +1,98 @@
+package util
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ExtractOptions selects which entries of an archive are extracted, and
+// where they go
+type ExtractOptions struct {
+	// StripComponents removes this many leading directories from every
+	// entry; entries with no path left are skipped
+	StripComponents int
+
+	// Include limits extraction to entries matching any of these globs,
+	// matched after stripping. Empty includes everything.
+	Include []string
+
+	// Exclude skips entries matching any of these globs, after Include
+	Exclude []string
+}
+
+// ValidateGlob checks that pattern is a valid include/exclude glob
+func ValidateGlob(pattern string) error {
+	if strings.Trim(pattern, "/") == "" {
+		return fmt.Errorf("empty glob %q", pattern)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid glob %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// strip removes the leading StripComponents directories from name, a clean
+// slash-separated relative path. It returns false if nothing is left.
+func (o ExtractOptions) strip(name string) (string, bool) {
+	if o.StripComponents <= 0 {
+		return name, true
+	}
+
+	parts := strings.Split(name, "/")
+	if len(parts) <= o.StripComponents {
+		return "", false
+	}
+	return strings.Join(parts[o.StripComponents:], "/"), true
+}
+
+// apply strips and filters an entry name, returning where it is extracted
+// relative to the destination, or false if it is skipped
+func (o ExtractOptions) apply(name string) (string, bool) {
+	name, ok := o.strip(name)
+	if !ok {
+		return "", false
+	}
+
+	if len(o.Include) > 0 && !matchAny(o.Include, name) {
+		return "", false
+	}
+	if matchAny(o.Exclude, name) {
+		return "", false
+	}
+	return name, true
+}
+
+// matchAny reports whether name matches any of the globs
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches name like a .gitignore pattern: a glob without a slash
+// matches any single path component ("*.exe", "docs"), and one with a slash
+// matches the path from the root ("bin/*"). A match on a directory covers
+// everything below it.
+func matchGlob(pattern, name string) bool {
+	pattern = strings.Trim(pattern, "/")
+
+	if !strings.Contains(pattern, "/") {
+		for _, part := range strings.Split(name, "/") {
+			if ok, _ := path.Match(pattern, part); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	for p := name; p != "." && p != ""; p = path.Dir(p) {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
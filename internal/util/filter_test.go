@@ -0,0 +1,218 @@
+package util
+
+import (
+	"archive/tar"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		// Without a slash, any path component
+		{"*.exe", "tool.exe", true},
+		{"*.exe", "bin/tool.exe", true},
+		{"docs", "pkg/docs/readme.md", true},
+		{"docs", "pkg/docsite/index.html", false},
+		{"tool?", "bin/tool1", true},
+		{"[ab]in", "bin/tool", true},
+
+		// With a slash, from the root, covering directories below
+		{"bin/*", "bin/tool", true},
+		{"bin/*", "pkg/bin/tool", false},
+		{"bin", "bin/sub/tool", true},
+		{"/bin/", "bin/tool", true},
+		{"pkg/bin", "pkg/bin/sub/tool", true},
+		{"pkg/*/tool", "pkg/bin/tool", true},
+		{"pkg/*/tool", "pkg/a/b/tool", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExtractOptionsApply(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ExtractOptions
+		in   string
+		want string
+		ok   bool
+	}{
+		{name: "no options", in: "tool-1.0/bin/tool", want: "tool-1.0/bin/tool", ok: true},
+		{name: "strip", opts: ExtractOptions{StripComponents: 1}, in: "tool-1.0/bin/tool", want: "bin/tool", ok: true},
+		{name: "strip everything", opts: ExtractOptions{StripComponents: 1}, in: "tool-1.0", ok: false},
+		{name: "strip too much", opts: ExtractOptions{StripComponents: 3}, in: "tool-1.0/bin/tool", ok: false},
+		{
+			name: "include after strip",
+			opts: ExtractOptions{StripComponents: 1, Include: []string{"bin/*"}},
+			in:   "tool-1.0/bin/tool", want: "bin/tool", ok: true,
+		},
+		{
+			name: "not included",
+			opts: ExtractOptions{StripComponents: 1, Include: []string{"bin/*"}},
+			in:   "tool-1.0/doc/README", ok: false,
+		},
+		{
+			name: "excluded",
+			opts: ExtractOptions{Exclude: []string{"*.md"}},
+			in:   "docs/README.md", ok: false,
+		},
+		{
+			name: "exclude wins over include",
+			opts: ExtractOptions{Include: []string{"bin"}, Exclude: []string{"*.pdb"}},
+			in:   "bin/tool.pdb", ok: false,
+		},
+	}
+
+	for _, tt := range tests {
+		got, ok := tt.opts.apply(tt.in)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%s: apply(%q) = %q, %v, want %q, %v", tt.name, tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestValidateGlob(t *testing.T) {
+	for _, pattern := range []string{"*.exe", "bin/*", "docs/", "[a-z]*"} {
+		if err := ValidateGlob(pattern); err != nil {
+			t.Errorf("ValidateGlob(%q): %v", pattern, err)
+		}
+	}
+	for _, pattern := range []string{"", "/", "[a-", "bin/[*"} {
+		if err := ValidateGlob(pattern); err == nil {
+			t.Errorf("ValidateGlob(%q) succeeded, want an error", pattern)
+		}
+	}
+}
+
+func TestExtractWithFilters(t *testing.T) {
+	src := writeTar(t, []tarEntry{
+		{name: "tool-1.0/", typeflag: tar.TypeDir},
+		{name: "tool-1.0/bin/tool", body: "tool", mode: 0755},
+		{name: "tool-1.0/bin/tool.pdb", body: "symbols"},
+		{name: "tool-1.0/doc/README", body: "docs"},
+		{name: "tool-1.0/LICENSE", body: "license"},
+
+		// Links whose target is filtered out are skipped
+		{name: "tool-1.0/bin/readme", typeflag: tar.TypeLink, linkname: "tool-1.0/doc/README"},
+		{name: "tool-1.0/bin/license", typeflag: tar.TypeSymlink, linkname: "../LICENSE"},
+
+		// Links to extracted files are kept
+		{name: "tool-1.0/bin/tool2", typeflag: tar.TypeLink, linkname: "tool-1.0/bin/tool"},
+	})
+
+	dest := t.TempDir()
+	opts := ExtractOptions{
+		StripComponents: 1,
+		Include:         []string{"bin/*"},
+		Exclude:         []string{"*.pdb"},
+	}
+	if err := ExtractWith(src, dest, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	err := filepath.Walk(dest, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(dest, path)
+		// A dangling symlink to the filtered out LICENSE is fine too
+		if _, err := os.Stat(path); err != nil {
+			return nil
+		}
+		got = append(got, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+
+	want := []string{"bin/tool", "bin/tool2"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("extracted %v, want %v", got, want)
+	}
+}
+
+func TestLinkCopyMissingTarget(t *testing.T) {
+	l, dest := newTestLinks(t, 10)
+	l.copies = []pendingLink{
+		{path: filepath.Join(dest, "readme"), target: filepath.Join(dest, "filtered"), name: "readme"},
+		{path: filepath.Join(dest, "copy"), target: filepath.Join(dest, "dir"), name: "copy"},
+	}
+
+	// Copies whose target was not extracted are skipped, the rest are made
+	if err := l.finish(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(dest, "readme")); err == nil {
+		t.Error("link to a missing target was created")
+	}
+	if _, err := os.Stat(filepath.Join(dest, "copy", "file")); err != nil {
+		t.Errorf("link to an extracted target was not copied: %v", err)
+	}
+}
+
+func TestExtractStrippedLinkEscapes(t *testing.T) {
+	canSymlink(t)
+
+	tests := []struct {
+		name    string
+		entries []tarEntry
+	}{
+		{
+			name: "through a stripped link",
+			entries: []tarEntry{
+				{name: "top/x", typeflag: tar.TypeSymlink, linkname: "."},
+				{name: "top/x/y", typeflag: tar.TypeSymlink, linkname: ".."},
+			},
+		},
+		{
+			name: "through a link replaced later",
+			entries: []tarEntry{
+				{name: "top/x", typeflag: tar.TypeSymlink, linkname: "."},
+				{name: "top/x/y", typeflag: tar.TypeSymlink, linkname: ".."},
+				{name: "top/x", body: "file"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, strip := range []int{0, 1} {
+			t.Run(fmt.Sprintf("%s, strip %d", tt.name, strip), func(t *testing.T) {
+				entries := tt.entries
+				if strip == 0 {
+					entries = nil
+					for _, e := range tt.entries {
+						e.name = strings.TrimPrefix(e.name, "top/")
+						entries = append(entries, e)
+					}
+				}
+
+				dest := t.TempDir()
+				err := ExtractWith(writeTar(t, entries), dest, ExtractOptions{StripComponents: strip})
+				if err == nil || !strings.Contains(err.Error(), "resolves outside") {
+					t.Errorf("got %v, want the link to be rejected", err)
+				}
+				if _, err := os.Lstat(filepath.Join(dest, "y")); err == nil {
+					t.Error("link escaping the destination was left behind")
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Archive formats recognised by DetectFormat
+const (
+	FormatZip    = "zip"
+	FormatTar    = "tar"
+	FormatTarGz  = "tar.gz"
+	FormatTarXz  = "tar.xz"
+	FormatTarBz2 = "tar.bz2"
+	FormatTarZst = "tar.zst"
+	FormatGz     = "gz"
+	FormatXz     = "xz"
+	FormatBz2    = "bz2"
+	FormatZst    = "zst"
+)
+
+// Magic numbers at the start of each format
+var (
+	zipMagic   = []byte("PK\x03\x04")
+	zipEmpty   = []byte("PK\x05\x06")
+	gzipMagic  = []byte{0x1f, 0x8b}
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	bzip2Magic = []byte("BZh")
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// tarMagicOffset is where the "ustar" magic sits in a tar header
+const tarMagicOffset = 257
+
+// ArchiveExtensions lists the file extensions of supported formats
+var ArchiveExtensions = []string{
+	".zip", ".tar", ".tar.gz", ".tgz", ".tar.xz", ".txz", ".tar.bz2", ".tbz2", ".tbz",
+	".tar.zst", ".tzst", ".gz", ".xz", ".bz2", ".zst",
+}
+
+// HasArchiveExtension reports whether name ends in a supported extension
+func HasArchiveExtension(name string) bool {
+	lower := strings.ToLower(name)
+	for _, ext := range ArchiveExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectFormat identifies the archive format of a file from its contents.
+// Compressed files are recognised as tarballs when the decompressed data
+// starts with a tar header, or when the name says so (for old tar files
+// without the "ustar" magic); otherwise they are single compressed files.
+func DetectFormat(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", filepath.Base(path), err)
+	}
+	defer file.Close()
+
+	br := bufio.NewReader(file)
+	head, _ := br.Peek(tarMagicOffset + 8)
+
+	if bytes.HasPrefix(head, zipMagic) || bytes.HasPrefix(head, zipEmpty) {
+		return FormatZip, nil
+	}
+
+	compression := compressionOf(head)
+	if compression == "" {
+		if isTarHeader(head) || strings.HasSuffix(strings.ToLower(path), ".tar") {
+			return FormatTar, nil
+		}
+		return "", fmt.Errorf("unsupported archive format: %s (not a zip, tar, gzip, xz, bzip2 or zstd file)",
+			filepath.Base(path))
+	}
+
+	r, err := decompress(br)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	inner := make([]byte, tarMagicOffset+8)
+	n, _ := io.ReadFull(r, inner)
+	if isTarHeader(inner[:n]) || strings.Contains(strings.ToLower(filepath.Base(path)), ".tar.") {
+		return "tar." + compression, nil
+	}
+	return compression, nil
+}
+
+// compressionOf returns the compression format of data starting with head,
+// or "" if it is not compressed
+func compressionOf(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		return FormatGz
+	case bytes.HasPrefix(head, xzMagic):
+		return FormatXz
+	case bytes.HasPrefix(head, bzip2Magic):
+		return FormatBz2
+	case bytes.HasPrefix(head, zstdMagic):
+		return FormatZst
+	}
+	return ""
+}
+
+// isTarHeader reports whether head starts with a POSIX or GNU tar header
+func isTarHeader(head []byte) bool {
+	return len(head) >= tarMagicOffset+5 &&
+		string(head[tarMagicOffset:tarMagicOffset+5]) == "ustar"
+}
+
+// decompress wraps r in a decompressor chosen from its leading magic
+// number; uncompressed data is returned as is
+func decompress(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(len(xzMagic))
+
+	switch compressionOf(head) {
+	case FormatGz:
+		gzr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+		return gzr, nil
+	case FormatXz:
+		xzr, err := xz.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create xz reader: %w", err)
+		}
+		return io.NopCloser(xzr), nil
+	case FormatBz2:
+		return io.NopCloser(bzip2.NewReader(br)), nil
+	case FormatZst:
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd reader: %w", err)
+		}
+		return zr.IOReadCloser(), nil
+	}
+	return io.NopCloser(br), nil
+}
+
+// decompressedName returns the file name of src without its compression
+// extension
+func decompressedName(src string) string {
+	name := filepath.Base(src)
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".gz", ".xz", ".bz2", ".zst"} {
+		if strings.HasSuffix(lower, ext) && len(name) > len(ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+	return name
+}
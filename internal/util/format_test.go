@@ -0,0 +1,126 @@
+package util
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// ustarBlock is a tar header block holding just the "ustar" magic
+var ustarBlock = append(append(make([]byte, tarMagicOffset), "ustar\x0000"...), make([]byte, 512-tarMagicOffset-8)...)
+
+// bzip2 has no encoder in the standard library, so these are precompressed
+var (
+	bz2Ustar = mustHex("425a683931415926535981805841000000c980c000400020001e000808200021a68d190832620bb108d348be91578bb9229c284840c02c2080")
+	bz2Hello = mustHex("425a6839314159265359c1c080e2000001410000100244a00030cd00c3462997177245385090c1c080e2")
+)
+
+func mustHex(s string) []byte {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func gzipData(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func xzData(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	w, err := xz.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zstdData(t *testing.T, data []byte) []byte {
+	w, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	return w.EncodeAll(data, nil)
+}
+
+func TestDetectFormat(t *testing.T) {
+	hello := []byte("hello\n")
+
+	tests := []struct {
+		name    string
+		file    string
+		data    []byte
+		want    string
+		wantErr string
+	}{
+		{name: "zip", file: "a.bin", data: append([]byte("PK\x03\x04"), make([]byte, 26)...), want: FormatZip},
+		{name: "empty zip", file: "a.bin", data: append([]byte("PK\x05\x06"), make([]byte, 18)...), want: FormatZip},
+		{name: "tar", file: "a.bin", data: ustarBlock, want: FormatTar},
+		{name: "old tar by name", file: "a.TAR", data: make([]byte, 512), want: FormatTar},
+		{name: "tar.gz", file: "a.bin", data: gzipData(t, ustarBlock), want: FormatTarGz},
+		{name: "tar.xz", file: "a.bin", data: xzData(t, ustarBlock), want: FormatTarXz},
+		{name: "tar.bz2", file: "a.bin", data: bz2Ustar, want: FormatTarBz2},
+		{name: "tar.zst", file: "a.bin", data: zstdData(t, ustarBlock), want: FormatTarZst},
+		{name: "gz", file: "a.gz", data: gzipData(t, hello), want: FormatGz},
+		{name: "xz", file: "a.xz", data: xzData(t, hello), want: FormatXz},
+		{name: "bz2", file: "a.bz2", data: bz2Hello, want: FormatBz2},
+		{name: "zst", file: "a.zst", data: zstdData(t, hello), want: FormatZst},
+		{name: "old tar.gz by name", file: "a.tar.gz", data: gzipData(t, make([]byte, 512)), want: FormatTarGz},
+		{name: "gz misnamed as tgz", file: "a.tgz", data: gzipData(t, hello), want: FormatGz},
+		{name: "unknown", file: "a.bin", data: []byte("#!/bin/sh\necho hello\n"), wantErr: "unsupported archive format: a.bin"},
+		{name: "unknown named zip", file: "a.zip", data: []byte("not a zip"), wantErr: "unsupported archive format"},
+		{name: "empty", file: "a.bin", data: nil, wantErr: "unsupported archive format"},
+		{name: "short zip magic", file: "a.bin", data: []byte("PK"), wantErr: "unsupported archive format"},
+		{name: "short tar", file: "a.bin", data: ustarBlock[:tarMagicOffset+3], wantErr: "unsupported archive format"},
+		{name: "truncated gzip", file: "a.gz", data: []byte{0x1f, 0x8b}, wantErr: "failed to create gzip reader"},
+		{name: "truncated xz", file: "a.xz", data: []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, wantErr: "failed to create xz reader"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := DetectFormat(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %q, %v, want an error mentioning %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("got %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := DetectFormat(filepath.Join(t.TempDir(), "missing.zip")); err == nil ||
+		!strings.Contains(err.Error(), "failed to open missing.zip") {
+		t.Errorf("missing file: got %v", err)
+	}
+}
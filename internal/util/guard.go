@@ -0,0 +1,142 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Limits applied when extracting archives, to stop archive bombs
+var (
+	// ExtractMaxSize caps the total uncompressed size of an archive
+	ExtractMaxSize int64 = 4 << 30
+
+	// ExtractMaxEntries caps the number of entries in an archive
+	ExtractMaxEntries = 100000
+
+	// ExtractMaxRatio caps the uncompressed size relative to the archive size
+	ExtractMaxRatio float64 = 100
+)
+
+// ratioMinSize is the amount of data that may always be extracted, so small
+// archives of highly compressible files are not mistaken for bombs
+const ratioMinSize = 16 << 20
+
+// extractGuard enforces the extraction limits over a whole archive
+type extractGuard struct {
+	archiveSize int64
+	entries     int
+	written     int64
+}
+
+// newExtractGuard creates a guard for the archive at src
+func newExtractGuard(src string) *extractGuard {
+	g := &extractGuard{}
+	if info, err := os.Stat(src); err == nil {
+		g.archiveSize = info.Size()
+	}
+	return g
+}
+
+// entry counts an archive entry and checks the entry limit
+func (g *extractGuard) entry(name string) error {
+	g.entries++
+	if g.entries > ExtractMaxEntries {
+		return fmt.Errorf("archive has too many entries: %s is entry %d (limit %d)",
+			name, g.entries, ExtractMaxEntries)
+	}
+	return nil
+}
+
+// copy copies the contents of entry name from src to dst, failing as soon
+// as the size or compression ratio limit is exceeded
+func (g *extractGuard) copy(dst io.Writer, src io.Reader, name string) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			g.written += int64(n)
+			if err := g.check(name); err != nil {
+				return err
+			}
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to extract %s: %w", name, err)
+		}
+	}
+}
+
+// reserve checks up front that size more bytes fit in the limits, for
+// formats that declare entry sizes
+func (g *extractGuard) reserve(size int64, name string) error {
+	if size > ExtractMaxSize-g.written {
+		return fmt.Errorf("archive too large: %s would exceed the %s limit", name, FormatSize(ExtractMaxSize))
+	}
+	return nil
+}
+
+func (g *extractGuard) check(name string) error {
+	if g.written > ExtractMaxSize {
+		return fmt.Errorf("archive too large: %s would exceed the %s limit", name, FormatSize(ExtractMaxSize))
+	}
+	if g.archiveSize > 0 && g.written > ratioMinSize &&
+		float64(g.written)/float64(g.archiveSize) > ExtractMaxRatio {
+		return fmt.Errorf("suspicious compression ratio: %s expands the archive to over %.0fx its size",
+			name, ExtractMaxRatio)
+	}
+	return nil
+}
+
+// entryName returns the clean, slash-separated path of entry name relative
+// to the destination. Absolute paths and paths leading outside it are
+// rejected; an empty result means the entry is the destination itself
+// (e.g. "./").
+func entryName(name string) (string, error) {
+	slashed := strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(slashed, "/") || filepath.VolumeName(name) != "" ||
+		(len(slashed) >= 2 && slashed[1] == ':') {
+		return "", fmt.Errorf("illegal file path: %s is absolute", name)
+	}
+
+	cleaned := path.Clean(slashed)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("illegal file path: %s is outside the destination", name)
+	}
+	if cleaned == "." {
+		return "", nil
+	}
+
+	return cleaned, nil
+}
+
+// checkMode rejects device files, pipes, sockets and setuid/setgid entries
+func checkMode(mode os.FileMode, name string) error {
+	switch {
+	case mode&(os.ModeDevice|os.ModeCharDevice) != 0:
+		return fmt.Errorf("illegal entry: %s is a device file", name)
+	case mode&(os.ModeNamedPipe|os.ModeSocket) != 0:
+		return fmt.Errorf("illegal entry: %s is a special file", name)
+	case mode&(os.ModeSetuid|os.ModeSetgid) != 0:
+		return fmt.Errorf("illegal entry: %s has the setuid or setgid bit set", name)
+	}
+	return nil
+}
+
+// fileMode normalises the permissions of an extracted file: executable if
+// any execute bit is set, otherwise read/write for the owner and readable by
+// everyone
+func fileMode(mode os.FileMode) os.FileMode {
+	if mode&0111 != 0 {
+		return 0755
+	}
+	return 0644
+}
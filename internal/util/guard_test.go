@@ -0,0 +1,115 @@
+package util
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEntryName(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "bin/tool", want: "bin/tool"},
+		{in: "./bin//tool", want: "bin/tool"},
+		{in: "bin\\tool.exe", want: "bin/tool.exe"},
+		{in: "a/../b", want: "b"},
+		{in: "./", want: ""},
+		{in: "../evil", wantErr: true},
+		{in: "a/../../evil", wantErr: true},
+		{in: "..\\evil", wantErr: true},
+		{in: "/etc/passwd", wantErr: true},
+		{in: "\\evil", wantErr: true},
+		{in: "C:\\Windows\\evil", wantErr: true},
+		{in: "c:evil", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := entryName(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("entryName(%q) = %q, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("entryName(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestCheckMode(t *testing.T) {
+	tests := []struct {
+		mode os.FileMode
+		want string
+	}{
+		{mode: 0644},
+		{mode: 0755},
+		{mode: os.ModeDir | 0755},
+		{mode: os.ModeDevice | 0600, want: "device file"},
+		{mode: os.ModeDevice | os.ModeCharDevice | 0600, want: "device file"},
+		{mode: os.ModeNamedPipe | 0600, want: "special file"},
+		{mode: os.ModeSocket | 0600, want: "special file"},
+		{mode: os.ModeSetuid | 0755, want: "setuid"},
+		{mode: os.ModeSetgid | 0755, want: "setgid"},
+	}
+
+	for _, tt := range tests {
+		err := checkMode(tt.mode, "entry")
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("checkMode(%v): %v", tt.mode, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("checkMode(%v) = %v, want an error mentioning %q", tt.mode, err, tt.want)
+		}
+	}
+}
+
+func TestFileMode(t *testing.T) {
+	tests := map[os.FileMode]os.FileMode{
+		0777: 0755,
+		0700: 0755,
+		0744: 0755,
+		0600: 0644,
+		0666: 0644,
+		0444: 0644,
+	}
+	for in, want := range tests {
+		if got := fileMode(in); got != want {
+			t.Errorf("fileMode(%o) = %o, want %o", in, got, want)
+		}
+	}
+}
+
+func TestExtractLimits(t *testing.T) {
+	defer func(size int64, entries int) {
+		ExtractMaxSize, ExtractMaxEntries = size, entries
+	}(ExtractMaxSize, ExtractMaxEntries)
+
+	files := []tarEntry{
+		{name: "a", body: strings.Repeat("a", 600)},
+		{name: "b", body: strings.Repeat("b", 600)},
+		{name: "c", body: "c"},
+	}
+	src := writeTar(t, files)
+
+	ExtractMaxSize, ExtractMaxEntries = 1000, 100
+	if err := Extract(src, t.TempDir()); err == nil || !strings.Contains(err.Error(), "archive too large") {
+		t.Errorf("size limit: got %v", err)
+	}
+
+	ExtractMaxSize, ExtractMaxEntries = 4<<20, 2
+	if err := Extract(src, t.TempDir()); err == nil || !strings.Contains(err.Error(), "too many entries") {
+		t.Errorf("entry limit: got %v", err)
+	}
+
+	ExtractMaxSize, ExtractMaxEntries = 4<<20, 3
+	if err := Extract(src, t.TempDir()); err != nil {
+		t.Errorf("within limits: %v", err)
+	}
+}
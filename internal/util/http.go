@@ -1,91 +1,206 @@
 package util
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
+// Retry policy for downloads
+var (
+	DownloadAttempts   = 5
+	DownloadBackoff    = time.Second
+	DownloadMaxBackoff = 30 * time.Second
+)
+
+// transientError marks a download failure that is worth retrying
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string { return e.err.Error() }
+func (e *transientError) Unwrap() error { return e.err }
+
+// DownloadOptions controls verification and reporting of a download
+type DownloadOptions struct {
+	// Checksum (algo:value) the file must match before it is moved to dest;
+	// empty skips verification
+	Checksum string
+
+	// Progress is called as data arrives; nil disables progress reporting
+	Progress func(current, total int64)
+
+	// Logf reports retries and resumes; nil prints to stdout
+	Logf func(format string, args ...any)
+}
+
 // Download downloads a file from URL to the specified destination
 func Download(url, dest string) error {
+	return DownloadFile(url, dest, DownloadOptions{})
+}
+
+// DownloadWithProgress downloads a file with progress indication
+func DownloadWithProgress(url, dest string) error {
+	return DownloadFile(url, dest, DownloadOptions{Progress: printProgress()})
+}
+
+// DownloadVerified downloads a file with progress indication and only moves
+// it to dest once it matches checksum (algo:value). An empty checksum skips
+// verification.
+func DownloadVerified(url, dest, checksum string) error {
+	return DownloadFile(url, dest, DownloadOptions{
+		Checksum: checksum,
+		Progress: printProgress(),
+	})
+}
+
+// DownloadFile fetches url into dest.part, resuming a previous partial
+// download with a Range request and retrying transient failures with
+// exponential backoff. The file is renamed to dest only once it is complete
+// and verified, so dest never holds a partial download.
+func DownloadFile(url, dest string, opts DownloadOptions) error {
+	if opts.Logf == nil {
+		opts.Logf = func(format string, args ...any) { fmt.Printf(format, args...) }
+	}
+
 	// Create destination directory
 	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
 		return fmt.Errorf("failed to create destination directory: %w", err)
 	}
 
-	// Create the file
-	out, err := os.Create(dest)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
-	}
-	defer out.Close()
+	part := dest + ".part"
 
-	// Get the data
-	resp, err := http.Get(url)
+	var err error
+	for attempt := 1; attempt <= DownloadAttempts; attempt++ {
+		if attempt > 1 {
+			delay := backoff(attempt - 1)
+			opts.Logf("Download failed: %v\nRetrying in %s (attempt %d/%d)...\n",
+				err, delay, attempt, DownloadAttempts)
+			time.Sleep(delay)
+		}
+
+		err = fetch(url, part, opts)
+		var transient *transientError
+		if err == nil || !errors.As(err, &transient) {
+			break
+		}
+	}
 	if err != nil {
-		return fmt.Errorf("failed to download: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	// Check server response
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad status: %s", resp.Status)
+	if opts.Checksum != "" {
+		if err := VerifyChecksum(part, opts.Checksum); err != nil {
+			os.Remove(part)
+			return err
+		}
 	}
 
-	// Write the body to file
-	_, err = io.Copy(out, resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+	if err := os.Rename(part, dest); err != nil {
+		return fmt.Errorf("failed to move download into place: %w", err)
 	}
 
 	return nil
 }
 
-// DownloadWithProgress downloads a file with progress indication
-func DownloadWithProgress(url, dest string) error {
-	// Create destination directory
-	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
-		return fmt.Errorf("failed to create destination directory: %w", err)
+// fetch downloads url into part, appending to it if the server supports
+// resuming from its current size
+func fetch(url, part string, opts DownloadOptions) error {
+	var offset int64
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
 	}
 
-	// Create the file
-	out, err := os.Create(dest)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
 	}
-	defer out.Close()
 
 	// Get the data
-	resp, err := http.Get(url)
+	resp, err := HTTPClient().Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to download: %w", err)
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = RedactURL(urlErr.URL)
+		}
+		return &transientError{fmt.Errorf("failed to download: %w", err)}
 	}
 	defer resp.Body.Close()
 
 	// Check server response
-	if resp.StatusCode != http.StatusOK {
+	flags := os.O_WRONLY | os.O_CREATE
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0 &&
+		strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)):
+		flags |= os.O_APPEND
+		opts.Logf("Resuming download at %d bytes\n", offset)
+	case resp.StatusCode == http.StatusOK:
+		// Server ignored the Range header, start over
+		offset = 0
+		flags |= os.O_TRUNC
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable || resp.StatusCode == http.StatusPartialContent:
+		// The partial file is stale or already complete; start over
+		os.Remove(part)
+		return &transientError{fmt.Errorf("cannot resume download: %s", resp.Status)}
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return &transientError{fmt.Errorf("bad status: %s", resp.Status)}
+	default:
 		return fmt.Errorf("bad status: %s", resp.Status)
 	}
 
-	// Get file size
-	size := resp.ContentLength
+	out, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer out.Close()
+
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
 
-	// Create progress reader
-	reader := &ProgressReader{
-		Reader: resp.Body,
-		Total:  size,
+	var reader io.Reader = resp.Body
+	if opts.Progress != nil {
+		reader = &ProgressReader{
+			Reader:     resp.Body,
+			Total:      total,
+			Current:    offset,
+			OnProgress: opts.Progress,
+		}
 	}
 
-	// Write the body to file with progress
-	_, err = io.Copy(out, reader)
+	// Write the body to file
+	n, err := io.Copy(out, reader)
+	if opts.Progress != nil {
+		opts.Progress(offset+n, -1) // Signal the end of this attempt
+	}
 	if err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+		return &transientError{fmt.Errorf("download interrupted: %w", err)}
 	}
 
-	fmt.Println() // New line after progress
-	return nil
+	if total >= 0 && offset+n != total {
+		return &transientError{fmt.Errorf("incomplete download: got %d of %d bytes", offset+n, total)}
+	}
+
+	return out.Close()
+}
+
+// backoff returns the delay before retry number n (starting at 1)
+func backoff(n int) time.Duration {
+	delay := DownloadBackoff << (n - 1)
+	if delay <= 0 || delay > DownloadMaxBackoff {
+		return DownloadMaxBackoff
+	}
+	return delay
 }
 
 // ProgressReader tracks download progress
@@ -93,13 +208,22 @@ type ProgressReader struct {
 	Reader   io.Reader
 	Total    int64
 	Current  int64
+
+	// OnProgress is called after every read; nil prints a percentage line
+	OnProgress func(current, total int64)
+
 	lastPct  int
 }
 
 func (pr *ProgressReader) Read(p []byte) (int, error) {
 	n, err := pr.Reader.Read(p)
 	pr.Current += int64(n)
-	
+
+	if pr.OnProgress != nil {
+		pr.OnProgress(pr.Current, pr.Total)
+		return n, err
+	}
+
 	if pr.Total > 0 {
 		pct := int(float64(pr.Current) / float64(pr.Total) * 100)
 		if pct != pr.lastPct && pct%10 == 0 {
@@ -107,6 +231,26 @@ func (pr *ProgressReader) Read(p []byte) (int, error) {
 			pr.lastPct = pct
 		}
 	}
-	
+
 	return n, err
 }
+
+// printProgress returns a progress callback printing a single updating
+// percentage line, ended with a newline when a download attempt finishes
+func printProgress() func(current, total int64) {
+	lastPct := 0
+	return func(current, total int64) {
+		if total < 0 {
+			fmt.Println() // New line after progress
+			lastPct = 0
+			return
+		}
+		if total > 0 {
+			pct := int(float64(current) / float64(total) * 100)
+			if pct != lastPct && pct%10 == 0 {
+				fmt.Printf("\rDownloading... %d%%", pct)
+				lastPct = pct
+			}
+		}
+	}
+}
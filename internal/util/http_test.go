@@ -0,0 +1,243 @@
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// downloadBody is the file served by the test servers
+const downloadBody = "hello world"
+
+// fastRetries makes retries immediate for the duration of a test
+func fastRetries(t *testing.T) {
+	t.Helper()
+	attempts, delay := DownloadAttempts, DownloadBackoff
+	t.Cleanup(func() { DownloadAttempts, DownloadBackoff = attempts, delay })
+	DownloadAttempts, DownloadBackoff = 3, time.Millisecond
+}
+
+// serve starts a server answering with handler, counting requests
+func serve(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, n int32)) (string, *int32) {
+	t.Helper()
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r, atomic.AddInt32(&requests, 1))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/file", &requests
+}
+
+// download fetches url into a fresh directory that may already hold a
+// partial download, returning the destination and what was logged
+func download(t *testing.T, url, partial string, opts DownloadOptions) (string, string, error) {
+	t.Helper()
+
+	dest := filepath.Join(t.TempDir(), "file")
+	if partial != "" {
+		if err := os.WriteFile(dest+".part", []byte(partial), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var log strings.Builder
+	opts.Logf = func(format string, args ...any) { fmt.Fprintf(&log, format, args...) }
+	err := DownloadFile(url, dest, opts)
+	return dest, log.String(), err
+}
+
+// checkDownload checks dest holds downloadBody and no partial download is left
+func checkDownload(t *testing.T, dest string) {
+	t.Helper()
+	if got, _ := os.ReadFile(dest); string(got) != downloadBody {
+		t.Errorf("downloaded %q, want %q", got, downloadBody)
+	}
+	if _, err := os.Stat(dest + ".part"); err == nil {
+		t.Error("partial download left behind")
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	fastRetries(t)
+	url, requests := serve(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		if r.Header.Get("Range") != "bytes=6-" {
+			t.Errorf("Range = %q, want bytes=6-", r.Header.Get("Range"))
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 6-%d/%d", len(downloadBody)-1, len(downloadBody)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(downloadBody[6:]))
+	})
+
+	dest, log, err := download(t, url, downloadBody[:6], DownloadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDownload(t, dest)
+	if !strings.Contains(log, "Resuming download at 6 bytes") {
+		t.Errorf("resume not reported: %q", log)
+	}
+	if *requests != 1 {
+		t.Errorf("%d requests, want 1", *requests)
+	}
+}
+
+func TestDownloadRangeIgnored(t *testing.T) {
+	fastRetries(t)
+	url, _ := serve(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		w.Write([]byte(downloadBody))
+	})
+
+	// The partial file must be replaced, not appended to
+	dest, _, err := download(t, url, "stale partial download", DownloadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDownload(t, dest)
+}
+
+func TestDownloadRangeNotSatisfiable(t *testing.T) {
+	fastRetries(t)
+	url, requests := serve(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		if r.Header.Get("Range") != "" {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		w.Write([]byte(downloadBody))
+	})
+
+	// The partial file is longer than the file, so it is stale
+	dest, _, err := download(t, url, downloadBody+" and more", DownloadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDownload(t, dest)
+	if *requests != 2 {
+		t.Errorf("%d requests, want 2", *requests)
+	}
+}
+
+func TestDownloadInterrupted(t *testing.T) {
+	fastRetries(t)
+	url, requests := serve(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		if n == 1 {
+			// Promise the whole file, then drop the connection halfway
+			w.Header().Set("Content-Length", fmt.Sprint(len(downloadBody)))
+			w.Write([]byte(downloadBody[:5]))
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		if r.Header.Get("Range") != "bytes=5-" {
+			t.Errorf("Range = %q, want bytes=5-", r.Header.Get("Range"))
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 5-%d/%d", len(downloadBody)-1, len(downloadBody)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(downloadBody[5:]))
+	})
+
+	dest, log, err := download(t, url, "", DownloadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDownload(t, dest)
+	if *requests != 2 {
+		t.Errorf("%d requests, want 2", *requests)
+	}
+	if !strings.Contains(log, "Retrying") || !strings.Contains(log, "Resuming download at 5 bytes") {
+		t.Errorf("retry and resume not reported: %q", log)
+	}
+}
+
+func TestDownloadRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		failures int32
+		requests int32
+		wantErr  bool
+	}{
+		{name: "server error then success", status: http.StatusServiceUnavailable, failures: 2, requests: 3},
+		{name: "rate limited then success", status: http.StatusTooManyRequests, failures: 1, requests: 2},
+		{name: "server errors exhaust the attempts", status: http.StatusInternalServerError, failures: 10, requests: 3, wantErr: true},
+		{name: "not found is not retried", status: http.StatusNotFound, failures: 10, requests: 1, wantErr: true},
+		{name: "forbidden is not retried", status: http.StatusForbidden, failures: 10, requests: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fastRetries(t)
+			url, requests := serve(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+				if n <= tt.failures {
+					w.WriteHeader(tt.status)
+					return
+				}
+				w.Write([]byte(downloadBody))
+			})
+
+			dest, _, err := download(t, url, "", DownloadOptions{})
+			if *requests != tt.requests {
+				t.Errorf("%d requests, want %d", *requests, tt.requests)
+			}
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), fmt.Sprint(tt.status)) {
+					t.Errorf("got %v, want an error with status %d", err, tt.status)
+				}
+				if _, err := os.Stat(dest); err == nil {
+					t.Error("failed download left a file at its destination")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkDownload(t, dest)
+		})
+	}
+}
+
+func TestDownloadChecksum(t *testing.T) {
+	fastRetries(t)
+	url, _ := serve(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		w.Write([]byte(downloadBody))
+	})
+
+	good := "sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	dest, _, err := download(t, url, "", DownloadOptions{Checksum: good})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDownload(t, dest)
+
+	// A mismatch leaves neither the file nor a partial download to resume
+	dest, _, err = download(t, url, "", DownloadOptions{Checksum: "sha256:" + strings.Repeat("0", 64)})
+	if err == nil {
+		t.Fatal("download with a wrong checksum succeeded")
+	}
+	for _, path := range []string{dest, dest + ".part"} {
+		if _, err := os.Stat(path); err == nil {
+			t.Errorf("%s left behind after a checksum mismatch", filepath.Base(path))
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	defer func(delay, max time.Duration) {
+		DownloadBackoff, DownloadMaxBackoff = delay, max
+	}(DownloadBackoff, DownloadMaxBackoff)
+	DownloadBackoff, DownloadMaxBackoff = time.Second, 5*time.Second
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for idx, w := range want {
+		if got := backoff(idx + 1); got != w {
+			t.Errorf("backoff(%d) = %s, want %s", idx+1, got, w)
+		}
+	}
+	if got := backoff(100); got != DownloadMaxBackoff {
+		t.Errorf("backoff(100) = %s, want the maximum", got)
+	}
+}
@@ -0,0 +1,260 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// linkExtractor creates the symlinks and hardlinks of an archive inside
+// dest, keeping every link inside it. Symlinks that cannot be created, e.g.
+// on Windows without Developer Mode, are replaced by copies of their targets
+// once the rest of the archive has been extracted.
+type linkExtractor struct {
+	dest     string
+	realDest string
+	symlinks []pendingLink
+	copies   []pendingLink
+
+	// guard counts copied link targets against the archive's limits
+	guard *extractGuard
+}
+
+// pendingLink is a symlink to be checked, or replaced by a copy of its
+// target, once the rest of the archive has been extracted. path is where it
+// is extracted, after stripping.
+type pendingLink struct {
+	path   string
+	target string
+	name   string
+}
+
+func newLinkExtractor(dest string, guard *extractGuard) (*linkExtractor, error) {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return nil, err
+	}
+	realDest, err := filepath.EvalSymlinks(dest)
+	if err != nil {
+		return nil, err
+	}
+	return &linkExtractor{dest: filepath.Clean(dest), realDest: realDest, guard: guard}, nil
+}
+
+// checkPath makes sure path, an entry about to be written, does not end up
+// outside dest by way of a symlink extracted earlier
+func (l *linkExtractor) checkPath(path, name string) error {
+	// Walk up to the deepest part of the path that already exists
+	existing := filepath.Dir(path)
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+
+	real, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", name, err)
+	}
+	if !within(l.realDest, real) {
+		return fmt.Errorf("illegal file path: %s is written through a link outside the destination", name)
+	}
+
+	// Replace rather than write through an existing symlink
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return os.Remove(path)
+	}
+	return nil
+}
+
+// symlink creates a symlink at path pointing to target, which must be
+// relative and resolve inside dest
+func (l *linkExtractor) symlink(path, target, name string) error {
+	if target == "" || filepath.IsAbs(target) || strings.HasPrefix(target, "/") || filepath.VolumeName(target) != "" {
+		return fmt.Errorf("illegal link: %s points to absolute path %s", name, target)
+	}
+
+	resolved := filepath.Join(filepath.Dir(path), target)
+	if !within(l.dest, resolved) {
+		return fmt.Errorf("illegal link: %s points outside the destination (%s)", name, target)
+	}
+
+	if err := l.checkPath(path, name); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	os.RemoveAll(path)
+
+	if err := os.Symlink(target, path); err != nil {
+		// Not permitted here; copy the target once it has been extracted
+		l.copies = append(l.copies, pendingLink{path: path, target: resolved, name: name})
+		return nil
+	}
+
+	// Record where the link really is, in case a later entry replaces a
+	// symlinked directory on the way to it
+	realDir, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", name, err)
+	}
+	l.symlinks = append(l.symlinks, pendingLink{path: filepath.Join(realDir, filepath.Base(path)), target: resolved, name: name})
+	return nil
+}
+
+// hardlink links path to target, a path relative to the archive root, or
+// copies it if hardlinks are not supported
+func (l *linkExtractor) hardlink(path, target, name string) error {
+	targetPath := filepath.Join(l.dest, target)
+	if filepath.IsAbs(target) || !within(l.dest, targetPath) {
+		return fmt.Errorf("illegal link: %s points outside the destination (%s)", name, target)
+	}
+
+	if err := l.checkPath(path, name); err != nil {
+		return err
+	}
+	if real, err := filepath.EvalSymlinks(targetPath); err == nil && !within(l.realDest, real) {
+		return fmt.Errorf("illegal link: %s points outside the destination (%s)", name, target)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	os.RemoveAll(path)
+
+	if err := os.Link(targetPath, path); err == nil {
+		return nil
+	}
+	if err := l.copyTree(targetPath, path, name); err != nil {
+		return fmt.Errorf("failed to copy hardlink %s: %w", name, err)
+	}
+	return nil
+}
+
+// finish verifies that created symlinks resolve inside dest and copies the
+// targets of symlinks that could not be created
+func (l *linkExtractor) finish() error {
+	for _, link := range l.symlinks {
+		real, err := filepath.EvalSymlinks(link.path)
+		if err != nil {
+			// Dangling links are left alone
+			continue
+		}
+		if !within(l.realDest, real) {
+			os.Remove(link.path)
+			return fmt.Errorf("illegal link: %s resolves outside the destination", link.name)
+		}
+	}
+
+	// Targets may themselves be pending copies, so repeat until no progress
+	pending := l.copies
+	for len(pending) > 0 {
+		var remaining []pendingLink
+		for _, link := range pending {
+			if _, err := os.Stat(link.target); err != nil {
+				remaining = append(remaining, link)
+				continue
+			}
+			// Copying a directory into itself would never finish
+			if within(link.target, link.path) {
+				return fmt.Errorf("illegal link: %s points to a directory containing itself", link.name)
+			}
+			if err := l.checkPath(link.target, link.name); err != nil {
+				return err
+			}
+			if err := l.copyTree(link.target, link.path, link.name); err != nil {
+				return fmt.Errorf("failed to copy link target for %s: %w", link.name, err)
+			}
+		}
+		// Like dangling symlinks, links to targets that were not extracted,
+		// e.g. because they were filtered out, are left out
+		if len(remaining) == len(pending) {
+			for _, link := range remaining {
+				fmt.Printf("Warning: skipping link %s: its target is not extracted\n", link.name)
+			}
+			break
+		}
+		pending = remaining
+	}
+
+	return nil
+}
+
+// within reports whether path is root or inside it
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator)) && !filepath.IsAbs(rel)
+}
+
+// copyTree copies a file, or a directory recursively, from src to dst in
+// place of link name. Copies count towards the archive's limits like
+// extracted entries, so many links to one large directory cannot be used
+// as a bomb.
+func (l *linkExtractor) copyTree(src, dst, name string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return l.copyRegular(src, dst, info.Mode().Perm(), name)
+	}
+
+	// Copying a directory into itself would never finish
+	if within(src, dst) {
+		return fmt.Errorf("illegal link: %s points to a directory containing itself", name)
+	}
+
+	return filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if fi.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		return l.copyRegular(path, target, fi.Mode().Perm(), name)
+	})
+}
+
+// copyRegular copies a single regular file
+func (l *linkExtractor) copyRegular(src, dst string, mode os.FileMode, name string) error {
+	if err := l.guard.entry(name); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := l.guard.copy(out, in, name); err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,165 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progressInterval limits how often the progress display is redrawn
+const progressInterval = 100 * time.Millisecond
+
+// MultiProgress renders one progress line per concurrent download, redrawing
+// them in place. When the output is not a terminal only finished downloads
+// are reported, one line each.
+type MultiProgress struct {
+	mu       sync.Mutex
+	out      io.Writer
+	live     bool
+	bars     []*ProgressBar
+	drawn    int
+	lastDraw time.Time
+}
+
+// ProgressBar is a single line of a MultiProgress
+type ProgressBar struct {
+	parent  *MultiProgress
+	label   string
+	current int64
+	total   int64
+	status  string
+	done    bool
+}
+
+// NewMultiProgress creates a progress display writing to stdout
+func NewMultiProgress() *MultiProgress {
+	return &MultiProgress{
+		out:  os.Stdout,
+		live: isTerminal(os.Stdout),
+	}
+}
+
+// Add adds a line for label and returns its bar
+func (m *MultiProgress) Add(label string) *ProgressBar {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b := &ProgressBar{parent: m, label: label, total: -1, status: "waiting"}
+	m.bars = append(m.bars, b)
+	m.redraw(true)
+	return b
+}
+
+// Update records progress, in the form used by DownloadOptions.Progress
+func (b *ProgressBar) Update(current, total int64) {
+	m := b.parent
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if total < 0 {
+		// End of a download attempt; keep the last known total
+		return
+	}
+	b.current, b.total, b.status = current, total, ""
+	m.redraw(false)
+}
+
+// Logf shows a message such as a retry notice in place of the percentage
+func (b *ProgressBar) Logf(format string, args ...any) {
+	m := b.parent
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b.status = strings.ReplaceAll(strings.TrimSpace(fmt.Sprintf(format, args...)), "\n", " ")
+	if !m.live {
+		fmt.Fprintf(m.out, "%s: %s\n", b.label, b.status)
+		return
+	}
+	m.redraw(true)
+}
+
+// Done marks the bar finished with a final status, e.g. "done" or an error
+func (b *ProgressBar) Done(status string) {
+	m := b.parent
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b.status, b.done = status, true
+	if !m.live {
+		fmt.Fprintln(m.out, b.line())
+		return
+	}
+	m.redraw(true)
+}
+
+// redraw repaints all lines, at most every progressInterval unless forced.
+// The caller must hold m.mu.
+func (m *MultiProgress) redraw(force bool) {
+	if !m.live || (!force && time.Since(m.lastDraw) < progressInterval) {
+		return
+	}
+	m.lastDraw = time.Now()
+
+	var sb strings.Builder
+	if m.drawn > 0 {
+		// Move the cursor back to the first line
+		fmt.Fprintf(&sb, "\x1b[%dA", m.drawn)
+	}
+	for _, b := range m.bars {
+		sb.WriteString("\r\x1b[2K")
+		sb.WriteString(b.line())
+		sb.WriteString("\n")
+	}
+	m.drawn = len(m.bars)
+
+	fmt.Fprint(m.out, sb.String())
+}
+
+// line formats the bar as a single line of text
+func (b *ProgressBar) line() string {
+	const width = 20
+
+	switch {
+	case b.done:
+		return fmt.Sprintf("  %-24s %s", b.label, b.status)
+	case b.status != "":
+		return fmt.Sprintf("  %-24s %s", b.label, b.status)
+	case b.total > 0:
+		pct := int(float64(b.current) / float64(b.total) * 100)
+		if pct > 100 {
+			pct = 100
+		}
+		filled := pct * width / 100
+		return fmt.Sprintf("  %-24s [%s%s] %3d%% %s",
+			b.label, strings.Repeat("#", filled), strings.Repeat(".", width-filled),
+			pct, FormatSize(b.current))
+	default:
+		return fmt.Sprintf("  %-24s %s", b.label, FormatSize(b.current))
+	}
+}
+
+// FormatSize formats a byte count in binary units, e.g. "1.5 MiB"
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,167 @@
+package version
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Constraint is a version constraint expression.
+//
+// Comparisons separated by commas must all hold; alternatives separated by
+// "||" are OR-ed together. Supported operators:
+//
+//	=1.2  ==1.2  1.2   exactly 1.2
+//	!=1.2              anything but 1.2
+//	>1.2  >=1.2        higher than (or equal to) 1.2
+//	<1.2  <=1.2        lower than (or equal to) 1.2
+//	~1.5               >=1.5, <1.6    (~1 is >=1, <2)
+//	^1.5               >=1.5, <2      (^0.4 is >=0.4, <0.5)
+//
+// The upper bounds of ~ and ^ also exclude prereleases of that version, so
+// ~1.5 does not match 1.6.0-rc.1.
+//
+// Example: ">=0.40, <1.0 || ~1.5".
+type Constraint struct {
+	original string
+	groups   [][]comparison
+}
+
+type comparison struct {
+	op      string
+	version *Version
+}
+
+// ParseConstraint parses a constraint expression
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{original: strings.TrimSpace(s)}
+
+	for _, alt := range strings.Split(s, "||") {
+		var group []comparison
+		for _, part := range strings.Split(alt, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				return nil, fmt.Errorf("invalid constraint %q: empty comparison", s)
+			}
+
+			cmps, err := parseComparison(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid constraint %q: %w", s, err)
+			}
+			group = append(group, cmps...)
+		}
+		c.groups = append(c.groups, group)
+	}
+
+	return c, nil
+}
+
+// IsConstraint reports whether s uses constraint syntax rather than being a
+// bare version
+func IsConstraint(s string) bool {
+	return strings.ContainsAny(s, "<>=!~^,|")
+}
+
+// String returns the constraint as originally written
+func (c *Constraint) String() string {
+	return c.original
+}
+
+// Check reports whether v satisfies the constraint
+func (c *Constraint) Check(v *Version) bool {
+	for _, group := range c.groups {
+		ok := true
+		for _, cmp := range group {
+			if !cmp.check(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckString parses s and reports whether it satisfies the constraint.
+// Unparseable versions never match.
+func (c *Constraint) CheckString(s string) bool {
+	v, err := Parse(s)
+	if err != nil {
+		return false
+	}
+	return c.Check(v)
+}
+
+func parseComparison(s string) ([]comparison, error) {
+	op := ""
+	for _, candidate := range []string{">=", "<=", "==", "!=", ">", "<", "=", "~", "^"} {
+		if strings.HasPrefix(s, candidate) {
+			op = candidate
+			break
+		}
+	}
+
+	v, err := Parse(strings.TrimSpace(s[len(op):]))
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "", "=", "==":
+		return []comparison{{op: "=", version: v}}, nil
+	case "~":
+		upper := bump(v, 1)
+		if len(v.Segments) == 1 {
+			upper = bump(v, 0)
+		}
+		return []comparison{{op: ">=", version: v}, {op: "<", version: upper}}, nil
+	case "^":
+		pos := 0
+		for pos < len(v.Segments)-1 && v.Segments[pos] == 0 {
+			pos++
+		}
+		return []comparison{{op: ">=", version: v}, {op: "<", version: bump(v, pos)}}, nil
+	default:
+		return []comparison{{op: op, version: v}}, nil
+	}
+}
+
+func (c comparison) check(v *Version) bool {
+	r := v.Compare(c.version)
+	switch c.op {
+	case "=":
+		return r == 0
+	case "!=":
+		return r != 0
+	case ">":
+		return r > 0
+	case ">=":
+		return r >= 0
+	case "<":
+		return r < 0
+	case "<=":
+		return r <= 0
+	}
+	return false
+}
+
+// bump returns the lowest version with the segment at pos incremented and
+// every later segment dropped, e.g. bump(1.5.2, 1) is 1.6-0, so that ~ and ^
+// bounds exclude prereleases of the next version
+func bump(v *Version, pos int) *Version {
+	segments := make([]int, pos+1)
+	copy(segments, v.Segments)
+	segments[pos]++
+
+	parts := make([]string, len(segments))
+	for idx, n := range segments {
+		parts[idx] = fmt.Sprint(n)
+	}
+
+	return &Version{
+		Original:   strings.Join(parts, ".") + "-0",
+		Segments:   segments,
+		Prerelease: []string{"0"},
+	}
+}
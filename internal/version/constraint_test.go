@@ -0,0 +1,86 @@
+package version
+
+import "testing"
+
+func TestConstraintCheck(t *testing.T) {
+	tests := []struct {
+		constraint string
+		matches    []string
+		rejects    []string
+	}{
+		{"1.5", []string{"1.5", "1.5.0", "v1.5"}, []string{"1.5.1", "1.6"}},
+		{"!=1.5", []string{"1.4", "1.5.1"}, []string{"1.5.0"}},
+		{">=1.2, <2", []string{"1.2", "1.9.9"}, []string{"1.1", "2.0"}},
+		{">1.2", []string{"1.2.1"}, []string{"1.2", "1.2.0-rc.1"}},
+
+		// Tilde allows patch releases, or minor releases with one segment
+		{"~1.5", []string{"1.5", "1.5.9"}, []string{"1.4.9", "1.6", "1.6.0-rc.1"}},
+		{"~1.5.2", []string{"1.5.2", "1.5.10"}, []string{"1.5.1", "1.6.0"}},
+		{"~1", []string{"1.0", "1.9"}, []string{"2.0", "2.0.0-alpha"}},
+
+		// Caret allows changes that keep the first non-zero segment
+		{"^1.5", []string{"1.5", "1.9.3"}, []string{"1.4", "2.0", "2.0.0-rc.1"}},
+		{"^0.4", []string{"0.4", "0.4.7"}, []string{"0.5", "0.3.9"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4", "0.1"}},
+
+		// Alternatives are OR-ed, comparisons within one AND-ed
+		{">=0.40, <1.0 || ~1.5", []string{"0.40", "0.99", "1.5.3"}, []string{"0.39", "1.0", "1.6"}},
+		{"1.0 || 2.0", []string{"1.0", "2.0"}, []string{"1.5"}},
+
+		// Prereleases only match bounds that allow them
+		{">=1.0.0-rc.1", []string{"1.0.0-rc.2", "1.0.0"}, []string{"1.0.0-beta"}},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Errorf("ParseConstraint(%q): %v", tt.constraint, err)
+			continue
+		}
+		for _, v := range tt.matches {
+			if !c.CheckString(v) {
+				t.Errorf("%q should match %s", tt.constraint, v)
+			}
+		}
+		for _, v := range tt.rejects {
+			if c.CheckString(v) {
+				t.Errorf("%q should not match %s", tt.constraint, v)
+			}
+		}
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	for _, s := range []string{"", ">=", ">=1.0,", "1.0 ||", "~x", ">=1.0, <two"} {
+		if _, err := ParseConstraint(s); err == nil {
+			t.Errorf("ParseConstraint(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestCheckStringUnparseable(t *testing.T) {
+	c, err := ParseConstraint(">=1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.CheckString("nightly") {
+		t.Error("an unparseable version should never match")
+	}
+}
+
+func TestIsConstraint(t *testing.T) {
+	tests := map[string]bool{
+		"1.5":        false,
+		"v0.44.1":    false,
+		"~0.44":      true,
+		"^1":         true,
+		">=1, <2":    true,
+		"1.0 || 2.0": true,
+		"!=1.0":      true,
+	}
+	for s, want := range tests {
+		if got := IsConstraint(s); got != want {
+			t.Errorf("IsConstraint(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version represents a parsed package version.
+//
+// Versions are dot-separated numeric segments with an optional leading "v",
+// an optional prerelease tag and optional build metadata, e.g. "1.2.3",
+// "v0.46.1", "1.5.2.2", "2.0.0-rc.1" or "1.0+build.5". Any number of numeric
+// segments is allowed; missing segments compare as zero, so "1.5" equals
+// "1.5.0".
+type Version struct {
+	Original   string
+	Segments   []int
+	Prerelease []string
+}
+
+// Parse parses a version string
+func Parse(s string) (*Version, error) {
+	raw := strings.TrimSpace(s)
+	str := strings.TrimPrefix(strings.TrimPrefix(raw, "v"), "V")
+
+	// Build metadata never affects precedence
+	if idx := strings.IndexByte(str, '+'); idx >= 0 {
+		str = str[:idx]
+	}
+
+	// Prerelease is either "-tag" or a tag glued to the last segment ("1.0rc1")
+	core, pre := str, ""
+	if idx := strings.IndexByte(str, '-'); idx >= 0 {
+		core, pre = str[:idx], str[idx+1:]
+		if pre == "" {
+			return nil, fmt.Errorf("invalid version %q: empty prerelease", s)
+		}
+	} else if idx := strings.IndexFunc(str, isLetter); idx > 0 {
+		core, pre = str[:idx], str[idx:]
+	}
+
+	if core == "" {
+		return nil, fmt.Errorf("invalid version %q", s)
+	}
+
+	parts := strings.Split(core, ".")
+	segments := make([]int, len(parts))
+	for idx, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %q: bad segment %q", s, part)
+		}
+		segments[idx] = n
+	}
+
+	v := &Version{
+		Original: raw,
+		Segments: segments,
+	}
+	if pre != "" {
+		v.Prerelease = strings.Split(pre, ".")
+	}
+
+	return v, nil
+}
+
+// String returns the version as originally written
+func (v *Version) String() string {
+	return v.Original
+}
+
+// IsPrerelease reports whether the version has a prerelease tag
+func (v *Version) IsPrerelease() bool {
+	return len(v.Prerelease) > 0
+}
+
+// Compare returns -1, 0 or 1 if v is lower than, equal to or higher than o
+func (v *Version) Compare(o *Version) int {
+	n := len(v.Segments)
+	if len(o.Segments) > n {
+		n = len(o.Segments)
+	}
+
+	for idx := 0; idx < n; idx++ {
+		a, b := segment(v.Segments, idx), segment(o.Segments, idx)
+		if a != b {
+			return cmpInt(a, b)
+		}
+	}
+
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+// Compare compares two version strings. Versions that cannot be parsed are
+// compared as plain strings so callers always get an ordering.
+func Compare(a, b string) int {
+	va, errA := Parse(a)
+	vb, errB := Parse(b)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	return va.Compare(vb)
+}
+
+// comparePrerelease follows semver precedence: a release is higher than any
+// prerelease, numeric identifiers compare numerically and are lower than
+// alphanumeric ones, and a shorter identifier list is lower when all
+// preceding identifiers are equal
+func comparePrerelease(a, b []string) int {
+	if len(a) == 0 || len(b) == 0 {
+		return -cmpInt(len(a), len(b))
+	}
+
+	for idx := 0; idx < len(a) && idx < len(b); idx++ {
+		na, errA := strconv.Atoi(a[idx])
+		nb, errB := strconv.Atoi(b[idx])
+
+		switch {
+		case errA == nil && errB == nil:
+			if na != nb {
+				return cmpInt(na, nb)
+			}
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		default:
+			if c := strings.Compare(a[idx], b[idx]); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return cmpInt(len(a), len(b))
+}
+
+func segment(segments []int, idx int) int {
+	if idx < len(segments) {
+		return segments[idx]
+	}
+	return 0
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func isLetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
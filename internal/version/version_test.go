@@ -0,0 +1,101 @@
+package version
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.5", "1.5.0", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.0+build.5", "1.0", 0},
+		{"1.10", "1.9", 1},
+		{"0.46.1", "0.46", 1},
+		{"1.5.2.2", "1.5.2.10", -1},
+		{"2.0", "10.0", -1},
+
+		// Prerelease precedence
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0rc1", "1.0", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-alpha.beta", "1.0.0-beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-beta.11", "1.0.0-rc.1", -1},
+		{"1.0.0-rc.1", "0.9", 1},
+
+		// Unparseable versions fall back to string comparison
+		{"nightly", "stable", -1},
+	}
+
+	for _, tt := range tests {
+		if got := Compare(tt.a, tt.b); got != tt.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := Compare(tt.b, tt.a); got != -tt.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in         string
+		segments   []int
+		prerelease []string
+		wantErr    bool
+	}{
+		{in: "1.2.3", segments: []int{1, 2, 3}},
+		{in: "v0.46.1", segments: []int{0, 46, 1}},
+		{in: "2.0.0-rc.1", segments: []int{2, 0, 0}, prerelease: []string{"rc", "1"}},
+		{in: "1.0rc1", segments: []int{1, 0}, prerelease: []string{"rc1"}},
+		{in: "1.0+build.5", segments: []int{1, 0}},
+		{in: "", wantErr: true},
+		{in: "1.0-", wantErr: true},
+		{in: "1..2", wantErr: true},
+		{in: "latest", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		v, err := Parse(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q) succeeded, want an error", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if !equal(v.Segments, tt.segments) || !equalStrings(v.Prerelease, tt.prerelease) {
+			t.Errorf("Parse(%q) = %v %v, want %v %v", tt.in, v.Segments, v.Prerelease, tt.segments, tt.prerelease)
+		}
+	}
+}
+
+func equal(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}